@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/coxlong/eureka/internal/app"
 	"github.com/coxlong/eureka/internal/pkg/config"
 )
 
+// shutdownTimeout 收到退出信号后等待处理中的请求结束的最长时间
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	var conf string
 	flag.StringVar(&conf, "conf", "./configs/dev.toml", "应用配置文件，默认为\"./configs/dev.toml\"")
@@ -17,17 +27,31 @@ func main() {
 		panic((err))
 	}
 
-	engine, err := app.Bootstrap(cfg)
+	// 收到SIGINT或SIGTERM时停止后台任务并关闭服务
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	engine, err := app.Bootstrap(ctx, cfg)
 	if err != nil {
 		panic(err)
 	}
-	if cfg.Server.HTTPS {
-		if err := engine.RunTLS(cfg.Server.Addr, cfg.Server.CrtFile, cfg.Server.KeyFile); err != nil {
-			panic(err)
+	server := &http.Server{Addr: cfg.Server.Addr, Handler: engine}
+	go func() {
+		var err error
+		if cfg.Server.HTTPS {
+			err = server.ListenAndServeTLS(cfg.Server.CrtFile, cfg.Server.KeyFile)
+		} else {
+			err = server.ListenAndServe()
 		}
-	} else {
-		if err := engine.Run(cfg.Server.Addr); err != nil {
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			panic(err)
 		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		panic(err)
 	}
 }
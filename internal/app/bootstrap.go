@@ -1,24 +1,33 @@
 package app
 
 import (
+	"context"
 	"encoding/gob"
 	"errors"
+	"time"
 
 	"github.com/coxlong/eureka/internal/handler"
 	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/blob"
 	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/crypto"
 	"github.com/coxlong/eureka/internal/pkg/log"
 	"github.com/coxlong/eureka/internal/repository"
 	"github.com/coxlong/eureka/internal/router"
 	"github.com/coxlong/eureka/internal/service"
 	gormsessions "github.com/gin-contrib/sessions/gorm"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func Bootstrap(cfg *config.Config) (*gin.Engine, error) {
+// trashPurgeInterval 清理回收站的间隔
+const trashPurgeInterval = time.Hour
+
+// Bootstrap 初始化各个服务并返回路由，ctx取消时停止后台任务
+func Bootstrap(ctx context.Context, cfg *config.Config) (*gin.Engine, error) {
 	gob.Register(model.User{})
 	if cfg.Env.Mode == config.Production {
 		gin.SetMode(gin.ReleaseMode)
@@ -36,13 +45,108 @@ func Bootstrap(cfg *config.Config) (*gin.Engine, error) {
 	}
 	sessionStore := gormsessions.NewStore(db, true, []byte(cfg.Authorization.SessionKey))
 
+	vectorIndex, err := repository.NewGormVectorIndex(db)
+	if err != nil {
+		return nil, err
+	}
 	conversationsRepo, err := repository.NewGormConversationRepository(db)
 	if err != nil {
 		return nil, err
 	}
-	conversationsService := service.NewConversationService(conversationsRepo)
+	conversationsService := service.NewConversationService(conversationsRepo, vectorIndex, &cfg.Trash)
+	if !cfg.Trash.DisablePurge {
+		go purgeTrash(ctx, conversationsService)
+	}
+
+	keysService, err := initKeysService(cfg, db)
+	if err != nil {
+		return nil, err
+	}
+
+	settingsRepo, err := repository.NewGormSettingsRepository(db)
+	if err != nil {
+		return nil, err
+	}
+	settingsService := service.NewSettingsService(settingsRepo)
+
+	usageRepo, err := repository.NewGormUsageRepository(db)
+	if err != nil {
+		return nil, err
+	}
+	usageService := service.NewUsageService(usageRepo)
+
+	quotaRepo, err := repository.NewGormQuotaRepository(db)
+	if err != nil {
+		return nil, err
+	}
+	quotaService := service.NewQuotaService(quotaRepo, usageRepo)
+
+	attachmentsService, err := initAttachmentsService(cfg, db)
+	if err != nil {
+		return nil, err
+	}
 
-	return router.Setup(&cfg.Env, sessionStore, handler.NewManager(cfg, conversationsService))
+	knowledgeRepo, err := repository.NewGormKnowledgeRepository(db)
+	if err != nil {
+		return nil, err
+	}
+	knowledgeService := service.NewKnowledgeService(knowledgeRepo, vectorIndex, &cfg.Knowledge)
+	searchService := service.NewSearchService(conversationsRepo, vectorIndex)
+
+	return router.Setup(&cfg.Env, sessionStore, handler.NewManager(cfg, conversationsService, keysService, settingsService, usageService, quotaService, attachmentsService, knowledgeService, searchService))
+}
+
+// purgeTrash 定期永久删除在回收站中超过保留天数的会话，直到ctx取消
+func purgeTrash(ctx context.Context, s service.ConversationsService) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		n, err := s.PurgeExpired(time.Now())
+		if err != nil {
+			log.Warn("purge trash failed", zap.Error(err))
+		} else if n > 0 {
+			log.Info("purged expired conversations", zap.Int("count", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// initKeysService Vault.Secret为空时不启用用户密钥库，只使用组织密钥
+func initKeysService(cfg *config.Config, db *gorm.DB) (service.KeysService, error) {
+	keysRepo, err := repository.NewGormKeysRepository(db)
+	if err != nil {
+		return nil, err
+	}
+	secret := cfg.Vault.Secret
+	if secret == "" {
+		log.Warn("vault secret is empty, user keys are disabled")
+		return service.NewKeysService(keysRepo, nil), nil
+	}
+	// 与会话的签名密钥分开，泄露或更换其中一个不影响另一个
+	if secret == cfg.Authorization.SessionKey {
+		return nil, errors.New("vault secret must differ from the session key")
+	}
+	cipher, err := crypto.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewKeysService(keysRepo, cipher), nil
+}
+
+func initAttachmentsService(cfg *config.Config, db *gorm.DB) (service.AttachmentsService, error) {
+	store, err := blob.New(&cfg.Attachments.Store)
+	if err != nil {
+		return nil, err
+	}
+	attachmentsRepo, err := repository.NewGormAttachmentsRepository(db)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewAttachmentsService(attachmentsRepo, store, &cfg.Attachments), nil
 }
 
 func initDB(cfg *config.Database) (*gorm.DB, error) {
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// cost 按路由配置的价格计算费用，价格为每百万token
+func cost(route *config.Model, promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*route.Price.Prompt + float64(completionTokens)*route.Price.Completion) / 1e6
+}
+
+// accountAnswers 把用量记到回答上。上游返回的usage为空时(如流式响应)在本地计算，
+// 多个回答时prompt只记在第一个回答上，completion按各自的内容计算
+func accountAnswers(route *config.Model, promptTokens int, usage openai.Usage, answers []model.Message) openai.Usage {
+	tok, err := tokenizer.ForModel(route.ModelID, route.Encoding)
+	if err != nil {
+		log.Warn("load tokenizer failed", zap.Error(err))
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = promptTokens
+	}
+	localCompletion := usage.CompletionTokens == 0
+	for i := range answers {
+		if i == 0 {
+			answers[i].PromptTokens = usage.PromptTokens
+		}
+		if len(answers) == 1 && !localCompletion {
+			answers[i].CompletionTokens = usage.CompletionTokens
+		} else if tok != nil {
+			answers[i].CompletionTokens = countAnswer(tok, answers[i])
+		}
+		if localCompletion {
+			usage.CompletionTokens += answers[i].CompletionTokens
+		}
+		answers[i].Cost = cost(route, answers[i].PromptTokens, answers[i].CompletionTokens)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// countAnswer 计算回答的token数，包括工具调用的函数名和参数
+func countAnswer(tok *tokenizer.Tokenizer, answer model.Message) int {
+	n := tok.Count(answer.Content)
+	if answer.FunctionCall != nil {
+		n += tok.Count(answer.FunctionCall.Name) + tok.Count(answer.FunctionCall.Arguments)
+	}
+	for _, call := range answer.ToolCalls {
+		n += tok.Count(call.Function.Name) + tok.Count(call.Function.Arguments)
+	}
+	return n
+}
+
+func (h *DefaultChatHandler) recordUsage(uid, cid, messageID string, route *config.Model, upstream config.Upstream, usage openai.Usage) {
+	recordUsage(h.usageService, uid, cid, messageID, route, upstream, usage)
+}
+
+// recordUsage 保存一次上游调用的用量，失败时只记录日志
+func recordUsage(usageService service.UsageService, uid, cid, messageID string, route *config.Model, upstream config.Upstream, usage openai.Usage) {
+	err := usageService.RecordUsage(uid, &model.UsageRecord{
+		ConversationID:   cid,
+		MessageID:        messageID,
+		Model:            route.Name,
+		Upstream:         provider.UpstreamName(upstream),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             cost(route, usage.PromptTokens, usage.CompletionTokens),
+	})
+	if err != nil {
+		log.Error("record usage failed", zap.Error(err))
+	}
+}
+
+// completionUsage 返回后台调用(摘要、标题)的用量，上游未返回时在本地计算
+func completionUsage(route *config.Model, prompt []openai.ChatCompletionMessage, response openai.ChatCompletionResponse) openai.Usage {
+	usage := response.Usage
+	if usage.PromptTokens != 0 || usage.CompletionTokens != 0 {
+		return usage
+	}
+	tok, err := tokenizer.ForModel(route.ModelID, route.Encoding)
+	if err != nil {
+		return usage
+	}
+	usage.PromptTokens = tok.CountMessages(prompt)
+	for _, choice := range response.Choices {
+		usage.CompletionTokens += tok.Count(choice.Message.Content)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/constants"
+	"github.com/gin-gonic/gin"
+)
+
+// adminSet 配置中的管理员
+type adminSet map[string]bool
+
+func newAdminSet(admins []string) adminSet {
+	set := adminSet{}
+	for _, uid := range admins {
+		set[uid] = true
+	}
+	return set
+}
+
+// targetUID 返回要操作的用户，管理员可以通过uid参数操作其他用户的数据
+func (a adminSet) targetUID(c *gin.Context) (string, bool) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	uid := c.Query("uid")
+	if uid == "" || uid == user.ID {
+		return user.ID, true
+	}
+	if !a[user.ID] {
+		c.String(403, "forbidden")
+		return "", false
+	}
+	return uid, true
+}
+
+// requireAdmin 当前用户不是管理员时返回403
+func (a adminSet) requireAdmin(c *gin.Context) bool {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	if !a[user.ID] {
+		c.String(403, "forbidden")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/constants"
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+)
+
+// resolveAttachments 把发给上游的消息中attachment://形式的图片替换为data URL或临时链接。
+// 只能引用当前用户上传的图片，保存的消息中仍然是attachment://地址
+func (h *DefaultChatHandler) resolveAttachments(ctx context.Context, req *ChatCompletionRequest) error {
+	for _, message := range req.ChatCompletionRequest.Messages {
+		for _, part := range message.MultiContent {
+			if part.ImageURL == nil {
+				continue
+			}
+			id, ok := model.AttachmentID(part.ImageURL.URL)
+			if !ok {
+				continue
+			}
+			url, err := h.attachments.ImageURL(ctx, req.caller.uid, id)
+			if errors.Is(err, service.ErrAttachmentNotFound) {
+				return &openai.APIError{
+					HTTPStatusCode: 400,
+					Type:           "invalid_request_error",
+					Message:        fmt.Sprintf("attachment %s does not exist", id),
+				}
+			}
+			if err != nil {
+				return err
+			}
+			part.ImageURL.URL = url
+		}
+	}
+	return nil
+}
+
+type AttachmentsHandler interface {
+	Upload(*gin.Context)
+	GetAttachment(*gin.Context)
+	DeleteAttachment(*gin.Context)
+}
+
+func NewAttachmentsHandler(service service.AttachmentsService) AttachmentsHandler {
+	return &DefaultAttachmentsHandler{service}
+}
+
+type DefaultAttachmentsHandler struct {
+	service service.AttachmentsService
+}
+
+// Upload 接收multipart表单中的file字段，返回的url可以作为image_url放在消息中
+func (h *DefaultAttachmentsHandler) Upload(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	defer reader.Close()
+	attachment, err := h.service.Upload(c, user.ID, file.Filename, reader)
+	switch {
+	case errors.Is(err, service.ErrAttachmentTooLarge):
+		c.String(413, err.Error())
+		return
+	case errors.Is(err, service.ErrUnsupportedImage):
+		c.String(415, err.Error())
+		return
+	case err != nil:
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, attachment)
+}
+
+// GetAttachment 返回图片内容，只有上传者可以访问
+func (h *DefaultAttachmentsHandler) GetAttachment(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	attachment, reader, err := h.service.Open(c, user.ID, c.Param("id"))
+	if errors.Is(err, service.ErrAttachmentNotFound) {
+		c.String(404, err.Error())
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	defer reader.Close()
+	c.DataFromReader(200, attachment.Size, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("inline", map[string]string{"filename": attachment.Name}),
+		"Cache-Control":          "private, max-age=86400",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func (h *DefaultAttachmentsHandler) DeleteAttachment(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	err := h.service.Delete(c, user.ID, c.Param("id"))
+	if errors.Is(err, service.ErrAttachmentNotFound) {
+		c.String(404, err.Error())
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"sync"
+)
+
+// streamEvent 一个SSE事件，Event为空时表示普通的data事件
+type streamEvent struct {
+	Event string
+	Data  []byte
+}
+
+// answerBroker 缓存一次回答生成过程中的所有事件，生成过程与客户端连接解耦，
+// 订阅者可以从任意位置开始读取并跟随后续事件
+type answerBroker struct {
+	mu     sync.Mutex
+	uid    string
+	events []streamEvent
+	done   bool
+	// cancelFunc 中止上游请求，cancelled标记生成是被用户主动取消的
+	cancelFunc context.CancelFunc
+	cancelled  bool
+	// notify 在有新事件或结束时关闭并替换
+	notify chan struct{}
+}
+
+func newAnswerBroker(uid string, cancelFunc context.CancelFunc) *answerBroker {
+	return &answerBroker{
+		uid:        uid,
+		notify:     make(chan struct{}),
+		cancelFunc: cancelFunc,
+	}
+}
+
+func (b *answerBroker) publish(event string, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return
+	}
+	b.events = append(b.events, streamEvent{event, data})
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+func (b *answerBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return
+	}
+	b.done = true
+	close(b.notify)
+}
+
+// cancel 取消正在进行的生成，生成已经结束时返回false
+func (b *answerBroker) cancel() bool {
+	b.mu.Lock()
+	if b.done {
+		b.mu.Unlock()
+		return false
+	}
+	b.cancelled = true
+	b.mu.Unlock()
+	b.cancelFunc()
+	return true
+}
+
+func (b *answerBroker) isCancelled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cancelled
+}
+
+// next 返回from之后的事件、是否已结束，以及用于等待新事件的channel
+func (b *answerBroker) next(from int) ([]streamEvent, bool, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var events []streamEvent
+	if from < len(b.events) {
+		events = b.events[from:]
+	}
+	return events, b.done, b.notify
+}
+
+// follow 从from之后开始把事件依次交给fn，直到生成结束、ctx结束或fn返回错误。
+// 传给fn的id为事件序号(从1开始)
+func (b *answerBroker) follow(ctx context.Context, from int, fn func(id int, event streamEvent) error) error {
+	for {
+		events, done, wait := b.next(from)
+		for i, event := range events {
+			if err := fn(from+i+1, event); err != nil {
+				return err
+			}
+		}
+		from += len(events)
+		if done && len(events) == 0 {
+			return nil
+		}
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
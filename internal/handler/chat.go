@@ -1,28 +1,96 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
 	"github.com/coxlong/eureka/internal/pkg/constants"
+	"github.com/coxlong/eureka/internal/pkg/hub"
 	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/provider"
 	"github.com/coxlong/eureka/internal/service"
+	"github.com/coxlong/eureka/internal/tool"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
 )
 
 type ChatHandler interface {
 	Completions(*gin.Context)
+	ResumeStream(*gin.Context)
+	Cancel(*gin.Context)
+	WebSocket(*gin.Context)
 }
 
-func NewChatHandler(service service.ConversationsService, baseURL string) ChatHandler {
-	return &DefaultChatHandler{service, baseURL}
+func NewChatHandler(
+	service service.ConversationsService,
+	keysService service.KeysService,
+	settingsService service.SettingsService,
+	usageService service.UsageService,
+	quotaService service.QuotaService,
+	attachmentsService service.AttachmentsService,
+	knowledgeService service.KnowledgeService,
+	searchService service.SearchService,
+	registry *provider.Registry,
+	tools *tool.Registry,
+	hub *hub.Hub,
+	cfg *config.OpenAI,
+	toolsCfg *config.Tools,
+	searchCfg *config.Search,
+	frontendAddr string,
+) ChatHandler {
+	maxToolIterations := toolsCfg.MaxIterations
+	if maxToolIterations <= 0 {
+		maxToolIterations = defaultMaxToolIterations
+	}
+	memory := cfg.Memory
+	if memory.TokenBudget <= 0 {
+		memory.TokenBudget = defaultMemoryBudget
+	}
+	if memory.RecentTokens <= 0 || memory.RecentTokens > memory.TokenBudget {
+		memory.RecentTokens = memory.TokenBudget / 2
+	}
+	title := cfg.Title
+	if title.Prompt == "" {
+		title.Prompt = defaultTitlePrompt
+	}
+	embedder := newEmbedder(registry, keysService, usageService, quotaService, cfg)
+	var indexer *messageIndexer
+	if !searchCfg.DisableSemantic {
+		indexer = newMessageIndexer(searchService, embedder)
+	}
+	return &DefaultChatHandler{
+		service:           service,
+		keysService:       keysService,
+		settingsService:   settingsService,
+		usageService:      usageService,
+		quotaService:      quotaService,
+		attachments:       attachmentsService,
+		knowledge:         knowledgeService,
+		embedder:          embedder,
+		indexer:           indexer,
+		registry:          registry,
+		tools:             tools,
+		retry:             provider.NewRetryPolicy(&cfg.Retry),
+		hub:               hub,
+		upgrader:          newUpgrader(frontendAddr),
+		brokers:           expirable.NewLRU[string, *answerBroker](maxBrokers, nil, brokerTTL),
+		summaryModel:      cfg.SummaryModel,
+		memory:            memory,
+		title:             title,
+		quota:             cfg.Quota,
+		maxToolIterations: maxToolIterations,
+	}
 }
 
 type ChatCompletionRequest struct {
@@ -31,6 +99,42 @@ type ChatCompletionRequest struct {
 	CurrentNodeID string          `json:"current_node_id"`
 	Messages      []model.Message `json:"messages"`
 	Save          bool            `json:"save"`
+	// ServerContext 为true时，客户端只需提供会话id、父节点current_node_id以及新的消息，
+	// 历史上下文由服务端根据存储的消息树重建
+	ServerContext bool `json:"server_context"`
+	// Memory 为true时配合server_context使用，较早的历史消息以滚动摘要代替
+	Memory bool `json:"memory"`
+	// ServerTools 启用的服务端工具名称，模型调用这些工具时由服务端执行后继续生成，
+	// 调用其他工具时照常返回给客户端
+	ServerTools []string `json:"server_tools"`
+	// Detach 为true时生成过程不随客户端断开而中止，完成后照常保存
+	Detach bool `json:"detach"`
+	// CollectionID 回答时检索的知识库，为空时使用会话关联的知识库。保存时会话关联到该知识库
+	CollectionID string `json:"collection_id"`
+	// origin 发起请求的WebSocket订阅，会话更新不再推送给它
+	origin string
+	// promptTokens 截断后发给上游的prompt的token数
+	promptTokens int
+	// caller 发起请求的用户，请求结束后的后台任务也使用它查找密钥
+	caller caller
+	// quota 检查限额后的剩余量，用于X-RateLimit-*响应头
+	quota *model.QuotaStatus
+	// citations 从知识库检索并注入prompt的分块，保存在回答中
+	citations []model.Citation
+}
+
+// caller 发起请求的用户及其请求头中的Authorization
+type caller struct {
+	uid           string
+	authorization string
+}
+
+func newCaller(c *gin.Context) caller {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	return caller{
+		uid:           user.ID,
+		authorization: c.Request.Header.Get("Authorization"),
+	}
 }
 
 func (req *ChatCompletionRequest) UnmarshalJSON(data []byte) error {
@@ -40,19 +144,97 @@ func (req *ChatCompletionRequest) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
-	for _, item := range aux.Messages {
-		aux.ChatCompletionRequest.Messages = append(aux.ChatCompletionRequest.Messages, openai.ChatCompletionMessage{
-			Role:    item.Role,
-			Content: item.Content,
-		})
+	if aux.ChatCompletionRequest == nil {
+		aux.ChatCompletionRequest = &openai.ChatCompletionRequest{}
 	}
+	aux.ChatCompletionRequest.Messages = toOpenaiMessages(aux.Messages)
 	*req = ChatCompletionRequest(aux)
 	return nil
 }
 
+func toOpenaiMessages(messages []model.Message) []openai.ChatCompletionMessage {
+	result := []openai.ChatCompletionMessage{}
+	for _, item := range messages {
+		result = append(result, openai.ChatCompletionMessage{
+			Role:         item.Role,
+			Content:      item.Content,
+			MultiContent: toOpenaiParts(item.MultiContent),
+			Name:         item.Name,
+			FunctionCall: toOpenaiFunctionCall(item.FunctionCall),
+			ToolCalls:    toOpenaiToolCalls(item.ToolCalls),
+			ToolCallID:   item.ToolCallID,
+		})
+	}
+	return result
+}
+
+func toOpenaiParts(parts []model.ContentPart) []openai.ChatMessagePart {
+	if len(parts) == 0 {
+		return nil
+	}
+	result := make([]openai.ChatMessagePart, 0, len(parts))
+	for _, part := range parts {
+		item := openai.ChatMessagePart{
+			Type: openai.ChatMessagePartType(part.Type),
+			Text: part.Text,
+		}
+		if part.ImageURL != nil {
+			item.ImageURL = &openai.ChatMessageImageURL{
+				URL:    part.ImageURL.URL,
+				Detail: openai.ImageURLDetail(part.ImageURL.Detail),
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+func toOpenaiFunctionCall(call *model.FunctionCall) *openai.FunctionCall {
+	if call == nil {
+		return nil
+	}
+	return &openai.FunctionCall{Name: call.Name, Arguments: call.Arguments}
+}
+
+func fromOpenaiFunctionCall(call *openai.FunctionCall) *model.FunctionCall {
+	if call == nil {
+		return nil
+	}
+	return &model.FunctionCall{Name: call.Name, Arguments: call.Arguments}
+}
+
+type ChatCompletionResponse struct {
+	openai.ChatCompletionResponse
+	MessageIDs []string `json:"message_ids,omitempty"`
+	// ToolMessages 服务端执行工具时产生的中间消息，按顺序排列
+	ToolMessages []model.Message `json:"tool_messages,omitempty"`
+}
+
 type DefaultChatHandler struct {
-	service service.ConversationsService
-	baseURL string
+	service         service.ConversationsService
+	keysService     service.KeysService
+	settingsService service.SettingsService
+	usageService    service.UsageService
+	quotaService    service.QuotaService
+	attachments     service.AttachmentsService
+	knowledge       service.KnowledgeService
+	embedder        *embedder
+	// indexer 为保存的消息计算向量，未开启语义搜索时为nil
+	indexer  *messageIndexer
+	registry *provider.Registry
+	tools    *tool.Registry
+	retry    provider.RetryPolicy
+	hub      *hub.Hub
+	upgrader *websocket.Upgrader
+	// brokers 按answerID缓存最近的回答事件，用于断线续传
+	brokers *expirable.LRU[string, *answerBroker]
+	// summaryModel 截断上下文时生成摘要使用的模型
+	summaryModel string
+	memory       config.Memory
+	title        config.Title
+	quota        config.Quota
+	// maxToolIterations 一次请求中服务端连续执行工具调用的最大轮数
+	maxToolIterations int
 }
 
 func (h *DefaultChatHandler) Completions(c *gin.Context) {
@@ -67,78 +249,333 @@ func (h *DefaultChatHandler) Completions(c *gin.Context) {
 		return
 	}
 
-	authHeader := c.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		c.JSON(400, openai.ErrorResponse{
-			Error: &openai.APIError{
-				Message: "Invalid Authorization",
-			},
-		})
+	req.caller = newCaller(c)
+	route, err := h.prepare(c.Request.Context(), &req)
+	setRateLimitHeaders(c, req.quota)
+	if err != nil {
+		eResp := toOpenaiErrorResponse(err)
+		c.JSON(eResp.Error.HTTPStatusCode, eResp)
 		return
 	}
-	tokenString := authHeader[7:]
+	c.Header("X-Prompt-Tokens", strconv.Itoa(req.promptTokens))
 
-	config := openai.DefaultConfig(tokenString)
-	if h.baseURL != "" {
-		config.BaseURL = h.baseURL
+	if !req.Stream {
+		h.complete(c, &req, route)
+		return
 	}
-	client := openai.NewClientWithConfig(config)
 
-	if !req.Stream {
-		response, err := client.CreateChatCompletion(c, *req.ChatCompletionRequest)
+	answerID, broker, err := h.startStream(c.Request.Context(), &req, route)
+	if err != nil {
+		eResp := toOpenaiErrorResponse(err)
+		c.JSON(eResp.Error.HTTPStatusCode, eResp)
+		return
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("X-Answer-ID", answerID)
+	followSSE(c, broker, 0)
+}
+
+// prepare 解析模型路由并预先检查限额，然后重建上下文、检索知识库、按上下文长度截断消息，
+// 最后按发给上游的prompt计入并检查限额，返回的错误均为*openai.APIError。
+// 调用前需要设置req.caller，WebSocket在后台goroutine中调用，不能使用gin.Context
+func (h *DefaultChatHandler) prepare(ctx context.Context, req *ChatCompletionRequest) (*config.Model, error) {
+	route, ok := h.registry.Resolve(req.Model)
+	if !ok {
+		return nil, &openai.APIError{
+			HTTPStatusCode: 404,
+			Code:           "model_not_found",
+			Type:           "invalid_request_error",
+			Message:        fmt.Sprintf("The model `%s` does not exist", req.Model),
+		}
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = route.MaxTokens
+	}
+	if req.Temperature == 0 {
+		req.Temperature = route.Temperature
+	}
+	if err := h.precheckQuota(req, route); err != nil {
+		return nil, err
+	}
+
+	if req.ServerContext {
+		if err := h.loadContext(ctx, req, route); err != nil {
+			return nil, &openai.APIError{
+				HTTPStatusCode: 400,
+				Message:        err.Error(),
+			}
+		}
+	}
+	if err := h.resolveAttachments(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := h.retrieveKnowledge(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := h.enableServerTools(req); err != nil {
+		return nil, err
+	}
+	if err := h.fitContext(ctx, req, route); err != nil {
+		return nil, err
+	}
+	if err := h.checkQuota(req, route); err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+// openStream 打开上游的流式响应，只在写出第一个token之前切换上游
+func (h *DefaultChatHandler) openStream(ctx context.Context, req *ChatCompletionRequest, route *config.Model) (provider.Stream, config.Upstream, error) {
+	return provider.Failover(ctx, h.retry, provider.Upstreams(route), func(ctx context.Context, upstream config.Upstream) (provider.Stream, error) {
+		client, upstreamReq, err := h.newClient(req.caller, &upstream, req.ChatCompletionRequest)
+		if err != nil {
+			return nil, err
+		}
+		return provider.OpenStream(ctx, client, upstreamReq)
+	})
+}
+
+// complete 处理非流式请求，模型调用启用的服务端工具时在服务端执行并继续请求，
+// 中间的工具调用和结果通过tool_messages返回
+func (h *DefaultChatHandler) complete(c *gin.Context, req *ChatCompletionRequest, route *config.Model) {
+	var usage openai.Usage
+	var steps []model.Message
+	for iteration := 0; ; iteration++ {
+		response, upstream, err := provider.Failover(c, h.retry, provider.Upstreams(route), func(ctx context.Context, upstream config.Upstream) (openai.ChatCompletionResponse, error) {
+			client, upstreamReq, err := h.newClient(req.caller, &upstream, req.ChatCompletionRequest)
+			if err != nil {
+				return openai.ChatCompletionResponse{}, err
+			}
+			return client.CreateChatCompletion(ctx, upstreamReq)
+		})
 		if err != nil {
 			eResp := toOpenaiErrorResponse(err)
 			c.JSON(eResp.Error.HTTPStatusCode, eResp)
 			return
 		}
-		c.JSON(http.StatusOK, response)
+		answers := make([]model.Message, len(response.Choices))
+		messageIDs := make([]string, len(response.Choices))
+		for i, choice := range response.Choices {
+			answers[i] = model.Message{
+				ID:           uuid.NewString(),
+				Parent:       req.CurrentNodeID,
+				Role:         openai.ChatMessageRoleAssistant,
+				Content:      choice.Message.Content,
+				FunctionCall: fromOpenaiFunctionCall(choice.Message.FunctionCall),
+				ToolCalls:    fromOpenaiToolCalls(choice.Message.ToolCalls),
+				Citations:    req.citations,
+				Upstream:     provider.UpstreamName(upstream),
+				Status:       model.MessageStatusFinished,
+			}
+			messageIDs[i] = answers[i].ID
+		}
+		usage = addUsage(usage, accountAnswers(route, req.promptTokens, response.Usage, answers))
+		if iteration < h.maxToolIterations && len(answers) == 1 && req.runsServerTools(answers[0].ToolCalls) {
+			steps = append(steps, h.runTools(c, req, route, answers[0])...)
+			continue
+		}
+
+		response.Usage = usage
+		if len(answers) > 0 {
+			response.ID = answers[0].ID
+		}
+		if req.Save && len(answers) > 0 {
+			created := req.ID == ""
+			if err := h.save(req.caller.uid, req, answers); err != nil {
+				log.Error("save failed", zap.Error(err))
+			} else if created {
+				h.autoTitleAsync(req, answers[0])
+			}
+		}
+		if len(answers) > 0 {
+			h.recordUsage(req.caller.uid, req.ID, answers[0].ID, route, upstream, usage)
+		}
+		c.JSON(http.StatusOK, ChatCompletionResponse{
+			ChatCompletionResponse: response,
+			MessageIDs:             messageIDs,
+			ToolMessages:           steps,
+		})
 		return
 	}
+}
 
-	stream, err := client.CreateChatCompletionStream(c, *req.ChatCompletionRequest)
+// startStream 打开上游流并在后台开始生成，SSE和WebSocket共用。
+// 非detach模式下parent结束时生成随之中止
+func (h *DefaultChatHandler) startStream(parent context.Context, req *ChatCompletionRequest, route *config.Model) (string, *answerBroker, error) {
+	// detach模式下上游请求使用服务端的context，客户端断开后继续生成并保存
+	ctx, cancel := context.WithCancel(parent)
+	if req.Detach {
+		ctx, cancel = context.WithTimeout(context.Background(), detachedTimeout)
+	}
+	stream, upstream, err := h.openStream(ctx, req, route)
 	if err != nil {
-		eResp := toOpenaiErrorResponse(err)
-		c.JSON(eResp.Error.HTTPStatusCode, eResp)
-		return
+		cancel()
+		return "", nil, err
 	}
-	defer stream.Close()
 
-	var answer string
 	answerID := uuid.NewString()
+	broker := newAnswerBroker(req.caller.uid, cancel)
+	h.brokers.Add(answerID, broker)
+	if len(req.citations) > 0 {
+		data, _ := json.Marshal(req.citations)
+		broker.publish(eventCitations, data)
+	}
+	go h.generate(ctx, cancel, stream, route, upstream, req, req.caller.uid, answerID, broker)
+	return answerID, broker, nil
+}
+
+// Cancel 取消正在生成的回答，只有发起请求的用户可以取消
+func (h *DefaultChatHandler) Cancel(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	broker, ok := h.brokers.Get(c.Param("answerID"))
+	if !ok || broker.uid != user.ID {
+		c.JSON(404, openai.ErrorResponse{
+			Error: &openai.APIError{
+				Message: "stream not found",
+			},
+		})
+		return
+	}
+	if !broker.cancel() {
+		c.JSON(409, openai.ErrorResponse{
+			Error: &openai.APIError{
+				Message: "generation already finished",
+			},
+		})
+		return
+	}
+	c.String(200, "success")
+}
+
+// ResumeStream 从Last-Event-ID之后重放回答的事件，并继续跟随正在生成的内容
+func (h *DefaultChatHandler) ResumeStream(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	broker, ok := h.brokers.Get(c.Param("answerID"))
+	if !ok || broker.uid != user.ID {
+		c.JSON(404, openai.ErrorResponse{
+			Error: &openai.APIError{
+				Message: "stream not found",
+			},
+		})
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	from := 0
+	if lastEventID != "" {
+		var err error
+		from, err = strconv.Atoi(lastEventID)
+		if err != nil || from < 0 {
+			c.JSON(400, openai.ErrorResponse{
+				Error: &openai.APIError{
+					Message: "invalid Last-Event-ID",
+				},
+			})
+			return
+		}
+	}
 	c.Header("Content-Type", "text/event-stream")
-	c.Stream(func(w io.Writer) bool {
-		response, err := stream.Recv()
-		if errors.Is(err, io.EOF) {
-			w.Write([]byte("data: [DONE]\n\n"))
-			return false
+	followSSE(c, broker, from)
+}
+
+// newClient 创建上游客户端，并把请求中的模型替换为上游实际的模型ID
+func (h *DefaultChatHandler) newClient(caller caller, upstream *config.Upstream, req *openai.ChatCompletionRequest) (provider.Provider, openai.ChatCompletionRequest, error) {
+	upstreamReq := *req
+	upstreamReq.Model = upstream.ModelID
+	tokenString, err := resolveAPIKey(h.keysService, caller, upstream)
+	if err != nil {
+		return nil, upstreamReq, err
+	}
+	client, err := provider.New(upstream.Provider, upstream.BaseURL, tokenString)
+	return client, upstreamReq, err
+}
+
+// resolveAPIKey 按上游配置的密钥来源查找调用上游使用的密钥，未指定来源时
+// 依次尝试用户密钥库和组织密钥。请求头中的密钥只转发给明确配置为request的上游
+func resolveAPIKey(keysService service.KeysService, caller caller, upstream *config.Upstream) (string, error) {
+	source := upstream.KeySource
+	if source == provider.KeySourceRequest {
+		if authHeader := caller.authorization; authHeader != "" {
+			if !strings.HasPrefix(authHeader, "Bearer ") {
+				return "", &openai.APIError{
+					HTTPStatusCode: 400,
+					Message:        "Invalid Authorization",
+				}
+			}
+			return authHeader[7:], nil
 		}
-		if err != nil {
-			return false
+	}
+	// 用户密钥按上游的密钥名称查找，以服务商类型保存的密钥不会发给代理等其他地址
+	if name := provider.VaultKeyName(*upstream); name != "" && (source == "" || source == provider.KeySourceUser) {
+		key, err := keysService.GetKey(caller.uid, name)
+		if err == nil {
+			return key, nil
+		}
+		if !errors.Is(err, service.ErrKeyNotFound) {
+			return "", err
 		}
-		answer += response.Choices[0].Delta.Content
-		response.ID = answerID
-		rByte, err := json.Marshal(response)
+	}
+	if (source == "" || source == provider.KeySourceOrg) && upstream.APIKey != "" {
+		return upstream.APIKey, nil
+	}
+	if upstream.Provider == provider.TypeOllama {
+		return "", nil
+	}
+	return "", &openai.APIError{
+		HTTPStatusCode: 401,
+		Message:        "No API key configured",
+	}
+}
 
+// loadContext 沿着父节点链重建历史消息，新消息依次挂在current_node_id之下，
+// 完成后current_node_id指向最后一条新消息
+func (h *DefaultChatHandler) loadContext(ctx context.Context, req *ChatCompletionRequest, route *config.Model) error {
+	if req.ID == "" {
+		return errors.New("server_context requires conversation id")
+	}
+	var prompt []openai.ChatCompletionMessage
+	if req.Memory {
+		history, summary, err := h.service.GetMessageChainWithSummary(req.ID, req.caller.uid, req.CurrentNodeID)
 		if err != nil {
-			return false
+			return err
 		}
-
-		w.Write([]byte("data: "))
-		w.Write(rByte)
-		w.Write([]byte("\n\n"))
-		return true
-	})
-	if req.Save {
-		if err := h.save(c, &req, answer, answerID); err != nil {
-			log.Error("save failed", zap.Error(err))
+		prompt = h.compactHistory(ctx, req, route, history, summary)
+	} else {
+		history, err := h.service.GetMessageChain(req.ID, req.caller.uid, req.CurrentNodeID)
+		if err != nil {
+			return err
+		}
+		prompt = toOpenaiMessages(history)
+	}
+	parent := req.CurrentNodeID
+	for i := range req.Messages {
+		if req.Messages[i].ID == "" {
+			req.Messages[i].ID = uuid.NewString()
 		}
+		req.Messages[i].Parent = parent
+		parent = req.Messages[i].ID
 	}
+	req.CurrentNodeID = parent
+	req.ChatCompletionRequest.Messages = append(prompt, toOpenaiMessages(req.Messages)...)
+	return nil
 }
 
 func toOpenaiErrorResponse(err error) openai.ErrorResponse {
-	if e, ok := err.(*openai.APIError); ok {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
 		return openai.ErrorResponse{
-			Error: e,
+			Error: apiErr,
+		}
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return openai.ErrorResponse{
+			Error: &openai.APIError{
+				HTTPStatusCode: reqErr.HTTPStatusCode,
+				Message:        reqErr.Error(),
+			},
 		}
 	}
 	return openai.ErrorResponse{
@@ -149,13 +586,20 @@ func toOpenaiErrorResponse(err error) openai.ErrorResponse {
 	}
 }
 
-func (h *DefaultChatHandler) save(c *gin.Context, req *ChatCompletionRequest, answer, answerID string) error {
+// save 保存本次对话，answers中的每个回答都作为CurrentNodeID的子节点，
+// 第一个回答作为会话的当前节点
+func (h *DefaultChatHandler) save(uid string, req *ChatCompletionRequest, answers []model.Message) error {
+	if len(answers) == 0 {
+		return nil
+	}
+	answerID := answers[0].ID
 	meta := model.ConversationMeta{
 		ID:            req.ID,
 		Model:         req.Model,
 		MaxTokens:     req.MaxTokens,
 		Temperature:   req.Temperature,
 		CurrentNodeID: answerID,
+		CollectionID:  req.CollectionID,
 	}
 	messages := []model.Message{}
 	for _, item := range req.Messages {
@@ -163,18 +607,28 @@ func (h *DefaultChatHandler) save(c *gin.Context, req *ChatCompletionRequest, an
 			messages = append(messages, item)
 		}
 	}
-	messages = append(messages, model.Message{
-		ID:      answerID,
-		Parent:  req.CurrentNodeID,
-		Role:    "assistant",
-		Content: answer,
-	})
+	messages = append(messages, answers...)
 
-	user := c.Value(constants.UserSessionKey).(model.User)
+	var err error
 	if meta.ID == "" {
 		meta.ID = answerID
-		return h.service.CreateConversation(user.ID, &meta, messages)
+		err = h.service.CreateConversation(uid, &meta, messages)
 	} else {
-		return h.service.UpdateConversation(user.ID, &meta, messages)
+		err = h.service.UpdateConversation(uid, &meta, messages)
 	}
+	if err != nil {
+		return err
+	}
+	// 后续生成标题等操作需要新会话的ID
+	req.ID = meta.ID
+	h.indexer.enqueue(req.caller, meta.ID, messages)
+	h.hub.Publish(uid, req.origin, hub.Event{
+		Type: constants.EventConversationMessage,
+		Data: map[string]any{
+			"conversation_id": meta.ID,
+			"current_node_id": meta.CurrentNodeID,
+			"messages":        messages,
+		},
+	})
+	return nil
 }
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/coxlong/eureka/internal/pkg/hub"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const (
+	wsPingPeriod = 30 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsWriteWait  = 10 * time.Second
+)
+
+// 客户端发送的消息类型
+const (
+	wsTypeCompletion = "completion"
+	wsTypeRegenerate = "regenerate"
+	wsTypeCancel     = "cancel"
+)
+
+// 服务端发送的消息类型，会话更新事件直接使用hub.Event的Type
+const (
+	wsTypeChunk = "chunk"
+	wsTypeError = "error"
+	wsTypeDone  = "done"
+)
+
+type wsRequest struct {
+	Type      string                 `json:"type"`
+	RequestID string                 `json:"request_id"`
+	Data      *ChatCompletionRequest `json:"data,omitempty"`
+}
+
+type wsResponse struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id,omitempty"`
+	AnswerID  string `json:"answer_id,omitempty"`
+	// ID 与SSE的事件id一致，可用于/chat/streams续传
+	ID   int `json:"id,omitempty"`
+	Data any `json:"data,omitempty"`
+}
+
+// wsConn 一个WebSocket连接，多个并发的生成共用同一个连接写出
+type wsConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	// requests request_id到answerID的映射，用于取消
+	mu       sync.Mutex
+	requests map[string]string
+}
+
+func (w *wsConn) write(resp wsResponse) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	w.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return w.conn.WriteJSON(resp)
+}
+
+func (w *wsConn) writeError(requestID string, err error) {
+	w.write(wsResponse{
+		Type:      wsTypeError,
+		RequestID: requestID,
+		Data:      toOpenaiErrorResponse(err),
+	})
+}
+
+func newUpgrader(frontendAddr string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" || origin == frontendAddr {
+				return true
+			}
+			u, err := url.Parse(origin)
+			return err == nil && u.Host == r.Host
+		},
+	}
+}
+
+// WebSocket 在一个连接上复用多个生成，并把会话的更新推送给同一用户的其他连接
+func (h *DefaultChatHandler) WebSocket(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Warn("websocket upgrade failed", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	// gin.Context在处理函数返回后会被复用，后台goroutine只能使用这里取出的值
+	caller := newCaller(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ws := &wsConn{
+		conn:     conn,
+		requests: map[string]string{},
+	}
+	sub := h.hub.Subscribe(caller.uid)
+	defer sub.Close()
+	go h.pushEvents(ctx, ws, sub)
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		var msg wsRequest
+		if err := conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Warn("websocket read failed", zap.Error(err))
+			}
+			return
+		}
+		switch msg.Type {
+		case wsTypeCompletion, wsTypeRegenerate:
+			if msg.Data == nil {
+				ws.writeError(msg.RequestID, &openai.APIError{HTTPStatusCode: 400, Message: "missing data"})
+				continue
+			}
+			req := msg.Data
+			req.Stream = true
+			req.origin = sub.ID
+			req.caller = caller
+			// 重新生成时沿用服务端保存的上下文，为current_node_id生成一个新的回答分支
+			if msg.Type == wsTypeRegenerate {
+				req.ServerContext = true
+				req.Messages = nil
+			}
+			go h.startWS(ctx, ws, msg.RequestID, req)
+		case wsTypeCancel:
+			ws.mu.Lock()
+			answerID := ws.requests[msg.RequestID]
+			ws.mu.Unlock()
+			if broker, ok := h.brokers.Get(answerID); ok && broker.uid == caller.uid {
+				broker.cancel()
+			}
+		default:
+			ws.writeError(msg.RequestID, &openai.APIError{HTTPStatusCode: 400, Message: "unknown message type"})
+		}
+	}
+}
+
+// startWS 与Completions的流式分支共用生成逻辑，事件通过WebSocket写出
+func (h *DefaultChatHandler) startWS(ctx context.Context, ws *wsConn, requestID string, req *ChatCompletionRequest) {
+	route, err := h.prepare(ctx, req)
+	if err != nil {
+		ws.writeError(requestID, err)
+		return
+	}
+	answerID, broker, err := h.startStream(ctx, req, route)
+	if err != nil {
+		ws.writeError(requestID, err)
+		return
+	}
+	ws.mu.Lock()
+	ws.requests[requestID] = answerID
+	ws.mu.Unlock()
+
+	go func() {
+		defer func() {
+			ws.mu.Lock()
+			delete(ws.requests, requestID)
+			ws.mu.Unlock()
+		}()
+		broker.follow(ctx, 0, func(id int, event streamEvent) error {
+			resp := wsResponse{
+				Type:      wsTypeChunk,
+				RequestID: requestID,
+				AnswerID:  answerID,
+				ID:        id,
+				Data:      json.RawMessage(event.Data),
+			}
+			if event.Event == "error" {
+				resp.Type = wsTypeError
+			} else if event.Event != "" {
+				resp.Type = event.Event
+			} else if string(event.Data) == "[DONE]" {
+				resp.Type = wsTypeDone
+				resp.Data = nil
+			}
+			return ws.write(resp)
+		})
+	}()
+}
+
+// pushEvents 转发会话更新事件并定时发送ping
+func (h *DefaultChatHandler) pushEvents(ctx context.Context, ws *wsConn, sub *hub.Subscription) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-sub.C:
+			ws.write(wsResponse{
+				Type: event.Type,
+				Data: event.Data,
+			})
+		case <-ticker.C:
+			ws.writeMu.Lock()
+			err := ws.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			ws.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
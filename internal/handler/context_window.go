@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const (
+	TruncationDropOldest = "drop_oldest"
+	TruncationKeepSystem = "keep_system"
+	TruncationSummarize  = "summarize"
+
+	summaryMaxTokens = 512
+	summaryPrompt    = "Summarize the following conversation concisely. Keep facts, decisions, names and open questions that later turns may rely on. Reply with the summary only."
+	summaryPrefix    = "Summary of the earlier conversation:\n"
+
+	// defaultCompletionReserve 请求和路由都没有指定max_tokens时为回答预留的token数，最多为上下文的四分之一
+	defaultCompletionReserve = 1024
+)
+
+// fitContext 统计prompt的token数，超出模型上下文时按路由配置的策略截断。
+// 需要为回答预留max_tokens，工具定义不能截断，只截断消息
+func (h *DefaultChatHandler) fitContext(ctx context.Context, req *ChatCompletionRequest, route *config.Model) error {
+	tok, err := tokenizer.ForModel(route.ModelID, route.Encoding)
+	if err != nil {
+		return err
+	}
+	messages := req.ChatCompletionRequest.Messages
+	toolTokens := tok.CountTools(req.Tools, req.Functions)
+	req.promptTokens = tok.CountMessages(messages) + toolTokens
+	if route.ContextWindow <= 0 {
+		return nil
+	}
+	completion := req.MaxTokens
+	if completion <= 0 {
+		completion = min(defaultCompletionReserve, route.ContextWindow/4)
+	}
+	budget := route.ContextWindow - completion
+	if req.promptTokens <= budget {
+		return nil
+	}
+
+	messageBudget := budget - toolTokens
+	var kept, dropped []openai.ChatCompletionMessage
+	switch route.Truncation {
+	case TruncationDropOldest:
+		kept, _ = truncateMessages(tok, messages, messageBudget, false)
+	case TruncationSummarize:
+		kept, dropped = truncateMessages(tok, messages, messageBudget, true)
+		if len(dropped) > 0 {
+			summary, _, err := h.summarize(ctx, req.caller, route, "", dropped)
+			if err != nil {
+				log.Warn("summarize context failed", zap.Error(err))
+				break
+			}
+			kept = insertSummary(tok, kept, summary, messageBudget)
+		}
+	default:
+		kept, _ = truncateMessages(tok, messages, messageBudget, true)
+	}
+
+	req.ChatCompletionRequest.Messages = kept
+	req.promptTokens = tok.CountMessages(kept) + toolTokens
+	if req.promptTokens > budget {
+		return &openai.APIError{
+			HTTPStatusCode: 400,
+			Code:           "context_length_exceeded",
+			Type:           "invalid_request_error",
+			Message: fmt.Sprintf("This model's maximum context length is %d tokens, the latest message and %d tokens of tool definitions need %d tokens plus %d for the completion",
+				route.ContextWindow, toolTokens, req.promptTokens, completion),
+		}
+	}
+	return nil
+}
+
+// truncateMessages 从最早的消息开始丢弃直到不超过budget，最后一条消息始终保留。
+// keepSystem为true时不丢弃system消息
+func truncateMessages(tok *tokenizer.Tokenizer, messages []openai.ChatCompletionMessage, budget int, keepSystem bool) (kept, dropped []openai.ChatCompletionMessage) {
+	total := tok.CountMessages(messages)
+	removed := make([]bool, len(messages))
+	for i := 0; i < len(messages)-1 && total > budget; i++ {
+		if keepSystem && messages[i].Role == openai.ChatMessageRoleSystem {
+			continue
+		}
+		removed[i] = true
+		total -= tok.CountMessage(messages[i])
+		// 工具调用的结果不能脱离发起调用的assistant消息单独存在
+		for i+1 < len(messages)-1 && messages[i+1].Role == openai.ChatMessageRoleTool {
+			i++
+			removed[i] = true
+			total -= tok.CountMessage(messages[i])
+		}
+	}
+	for i, message := range messages {
+		if removed[i] {
+			dropped = append(dropped, message)
+		} else {
+			kept = append(kept, message)
+		}
+	}
+	return kept, dropped
+}
+
+// insertSummary 把摘要作为system消息插入到开头的system消息之后，
+// 摘要使消息再次超出budget时继续丢弃最早的非system消息
+func insertSummary(tok *tokenizer.Tokenizer, messages []openai.ChatCompletionMessage, summary string, budget int) []openai.ChatCompletionMessage {
+	i := 0
+	for i < len(messages) && messages[i].Role == openai.ChatMessageRoleSystem {
+		i++
+	}
+	result := make([]openai.ChatCompletionMessage, 0, len(messages)+1)
+	result = append(result, messages[:i]...)
+	result = append(result, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: summaryPrefix + summary,
+	})
+	result = append(result, messages[i:]...)
+	result, _ = truncateMessages(tok, result, budget, true)
+	return result
+}
+
+// summarize 调用摘要模型总结被截断的消息，previous不为空时与之前的摘要合并
+func (h *DefaultChatHandler) summarize(ctx context.Context, caller caller, route *config.Model, previous string, messages []openai.ChatCompletionMessage) (string, config.Upstream, error) {
+	if h.summaryModel != "" {
+		summaryRoute, ok := h.registry.Resolve(h.summaryModel)
+		if !ok {
+			return "", config.Upstream{}, fmt.Errorf("summary model %s does not exist", h.summaryModel)
+		}
+		route = summaryRoute
+	}
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "%s%s\n\n", summaryPrefix, previous)
+	}
+	for _, message := range messages {
+		text := provider.MessageText(message)
+		if message.Role == openai.ChatMessageRoleSystem || text == "" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", message.Role, text)
+	}
+	req := &openai.ChatCompletionRequest{
+		Model:     route.Name,
+		MaxTokens: summaryMaxTokens,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: summaryPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: transcript.String()},
+		},
+	}
+	response, upstream, err := provider.Failover(ctx, h.retry, provider.Upstreams(route), func(ctx context.Context, upstream config.Upstream) (openai.ChatCompletionResponse, error) {
+		client, upstreamReq, err := h.newClient(caller, &upstream, req)
+		if err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+		return client.CreateChatCompletion(ctx, upstreamReq)
+	})
+	if err != nil {
+		return "", upstream, err
+	}
+	h.recordUsage(caller.uid, "", "", route, upstream, completionUsage(route, req.Messages, response))
+	if len(response.Choices) == 0 {
+		return "", upstream, fmt.Errorf("summary model returned no choices")
+	}
+	return response.Choices[0].Message.Content, upstream, nil
+}
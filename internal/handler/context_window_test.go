@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/sashabaranov/go-openai"
+)
+
+func message(role, content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: role, Content: content}
+}
+
+// words 返回n个重复单词，每个单词约一个token
+func words(n int) string {
+	return strings.Repeat(" word", n)
+}
+
+// conversation 包含system消息和一次工具调用的对话
+func conversation() []openai.ChatCompletionMessage {
+	call := message(openai.ChatMessageRoleAssistant, "")
+	call.ToolCalls = []openai.ToolCall{
+		{ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "calculator", Arguments: `{"expression":"1+2"}`}},
+		{ID: "call_2", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "calculator", Arguments: `{"expression":"3*4"}`}},
+	}
+	result1 := message(openai.ChatMessageRoleTool, "3")
+	result1.ToolCallID = "call_1"
+	result2 := message(openai.ChatMessageRoleTool, "12")
+	result2.ToolCallID = "call_2"
+	return []openai.ChatCompletionMessage{
+		message(openai.ChatMessageRoleSystem, "system"+words(20)),
+		message(openai.ChatMessageRoleUser, "first"+words(50)),
+		call,
+		result1,
+		result2,
+		message(openai.ChatMessageRoleUser, "second"+words(50)),
+		message(openai.ChatMessageRoleAssistant, "answer"+words(50)),
+		message(openai.ChatMessageRoleUser, "last"+words(10)),
+	}
+}
+
+// pick 返回messages中指定下标的消息
+func pick(messages []openai.ChatCompletionMessage, indexes ...int) []openai.ChatCompletionMessage {
+	result := make([]openai.ChatCompletionMessage, len(indexes))
+	for i, index := range indexes {
+		result[i] = messages[index]
+	}
+	return result
+}
+
+func contents(messages []openai.ChatCompletionMessage) string {
+	parts := make([]string, len(messages))
+	for i, item := range messages {
+		parts[i] = item.Role + ":" + strings.Fields(item.Content + " -")[0]
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTruncateMessages(t *testing.T) {
+	tok, err := tokenizer.ForModel("gpt-4", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := conversation()
+	count := func(indexes ...int) int {
+		return tok.CountMessages(pick(messages, indexes...))
+	}
+	cases := []struct {
+		name       string
+		budget     int
+		keepSystem bool
+		kept       []int
+	}{
+		{"fits", count(0, 1, 2, 3, 4, 5, 6, 7), true, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{"keep system", count(0, 5, 6, 7), true, []int{0, 5, 6, 7}},
+		{"drop oldest", count(5, 6, 7), false, []int{5, 6, 7}},
+		// 丢弃assistant的工具调用时一起丢弃工具结果，不留下孤立的tool消息
+		{"tool results follow their call", count(0, 3, 4, 5, 6, 7), true, []int{0, 5, 6, 7}},
+		{"keep system and last", 1, true, []int{0, 7}},
+		{"only last", 1, false, []int{7}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kept, dropped := truncateMessages(tok, messages, c.budget, c.keepSystem)
+			if got, want := contents(kept), contents(pick(messages, c.kept...)); got != want {
+				t.Fatalf("kept %s, want %s", got, want)
+			}
+			if len(kept)+len(dropped) != len(messages) {
+				t.Fatalf("kept %d and dropped %d of %d messages", len(kept), len(dropped), len(messages))
+			}
+		})
+	}
+}
+
+func TestInsertSummary(t *testing.T) {
+	tok, _ := tokenizer.ForModel("gpt-4", "")
+	messages := pick(conversation(), 0, 5, 6, 7)
+	result := insertSummary(tok, messages, "short summary", 10000)
+	if got := contents(result); got != "system:system system:Summary user:second assistant:answer user:last" {
+		t.Fatalf("got %s", got)
+	}
+	if result[1].Content != summaryPrefix+"short summary" {
+		t.Fatalf("got summary %q", result[1].Content)
+	}
+	// 加入摘要后超出budget时继续丢弃最早的非system消息
+	result = insertSummary(tok, messages, "short summary", tok.CountMessages(messages))
+	if got := contents(result); got != "system:system system:Summary assistant:answer user:last" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func newFitRequest(messages []openai.ChatCompletionMessage) *ChatCompletionRequest {
+	return &ChatCompletionRequest{
+		ChatCompletionRequest: &openai.ChatCompletionRequest{Model: "gpt-4", Messages: messages},
+		caller:                caller{uid: "u1"},
+	}
+}
+
+func TestFitContext(t *testing.T) {
+	tok, _ := tokenizer.ForModel("gpt-4", "")
+	h := &DefaultChatHandler{}
+	messages := conversation()
+	total := tok.CountMessages(messages)
+
+	// 没有配置上下文长度时只计数
+	req := newFitRequest(messages)
+	req.Tools = []openai.Tool{{Type: openai.ToolTypeFunction, Function: openai.FunctionDefinition{Name: "calculator"}}}
+	toolTokens := tok.CountTools(req.Tools, nil)
+	if err := h.fitContext(context.Background(), req, &config.Model{Upstream: config.Upstream{ModelID: "gpt-4"}}); err != nil {
+		t.Fatal(err)
+	}
+	if req.promptTokens != total+toolTokens || len(req.ChatCompletionRequest.Messages) != len(messages) {
+		t.Fatalf("got %d tokens %d messages", req.promptTokens, len(req.ChatCompletionRequest.Messages))
+	}
+
+	// 未指定max_tokens时预留上下文的四分之一
+	route := &config.Model{Upstream: config.Upstream{ModelID: "gpt-4"}, ContextWindow: total}
+	req = newFitRequest(messages)
+	if err := h.fitContext(context.Background(), req, route); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(req.ChatCompletionRequest.Messages); got != "system:system user:second assistant:answer user:last" {
+		t.Fatalf("got %s", got)
+	}
+	if req.promptTokens > total-total/4 {
+		t.Fatalf("prompt %d leaves no room for the completion", req.promptTokens)
+	}
+
+	// 工具定义占用的token从消息的预算中扣除
+	route.ContextWindow = tok.CountMessages(pick(messages, 0, 5, 6, 7)) + 100
+	req = newFitRequest(messages)
+	req.MaxTokens = 100
+	if err := h.fitContext(context.Background(), req, route); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(req.ChatCompletionRequest.Messages); got != "system:system user:second assistant:answer user:last" {
+		t.Fatalf("without tools got %s", got)
+	}
+	req = newFitRequest(messages)
+	req.MaxTokens = 100
+	req.Tools = []openai.Tool{{Type: openai.ToolTypeFunction, Function: openai.FunctionDefinition{Name: "calculator", Description: "evaluate an expression"}}}
+	if err := h.fitContext(context.Background(), req, route); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(req.ChatCompletionRequest.Messages); got != "system:system assistant:answer user:last" {
+		t.Fatalf("with tools got %s", got)
+	}
+	if req.promptTokens != tok.CountMessages(req.ChatCompletionRequest.Messages)+tok.CountTools(req.Tools, nil) {
+		t.Fatalf("prompt tokens %d do not include the tools", req.promptTokens)
+	}
+
+	// 只剩最后一条消息仍然放不下
+	req = newFitRequest(messages)
+	req.MaxTokens = route.ContextWindow - 10
+	err := h.fitContext(context.Background(), req, route)
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != 400 || apiErr.Code != "context_length_exceeded" {
+		t.Fatalf("got %v, want context_length_exceeded", err)
+	}
+}
+
+type fakeUsage struct {
+	records []model.UsageRecord
+}
+
+func (u *fakeUsage) RecordUsage(uid string, record *model.UsageRecord) error {
+	u.records = append(u.records, *record)
+	return nil
+}
+
+func (u *fakeUsage) GetUsage(uid, period string, from, to time.Time) ([]model.UsageStat, error) {
+	return nil, nil
+}
+
+func TestFitContextSummarize(t *testing.T) {
+	var transcript string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		transcript = req.Messages[1].Content
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"short summary"},"finish_reason":"stop"}],"usage":{"prompt_tokens":100,"completion_tokens":3}}`)
+	}))
+	defer server.Close()
+	usage := &fakeUsage{}
+	registry := provider.NewRegistry(&config.OpenAI{BaseURL: server.URL, APIKey: "key", KeySource: provider.KeySourceOrg})
+	h := &DefaultChatHandler{registry: registry, usageService: usage, retry: provider.NewRetryPolicy(&config.Retry{})}
+	tok, _ := tokenizer.ForModel("gpt-4", "")
+	messages := conversation()
+
+	route, _ := registry.Resolve("gpt-4")
+	route.Truncation = TruncationSummarize
+	summary := tok.CountMessage(message(openai.ChatMessageRoleSystem, summaryPrefix+"short summary"))
+	route.ContextWindow = tok.CountMessages(pick(messages, 0, 5, 6, 7)) + summary + 100
+	req := newFitRequest(messages)
+	req.MaxTokens = 100
+	if err := h.fitContext(context.Background(), req, route); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(req.ChatCompletionRequest.Messages); got != "system:system system:Summary user:second assistant:answer user:last" {
+		t.Fatalf("got %s", got)
+	}
+	// 摘要只包含被丢弃的消息
+	if !strings.Contains(transcript, "user: first") || strings.Contains(transcript, "second") || strings.Contains(transcript, "system") {
+		t.Fatalf("got transcript %q", transcript)
+	}
+	if len(usage.records) != 1 || usage.records[0].PromptTokens != 100 {
+		t.Fatalf("got usage %+v", usage.records)
+	}
+}
@@ -1,24 +1,47 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/coxlong/eureka/internal/model"
 	"github.com/coxlong/eureka/internal/pkg/constants"
+	"github.com/coxlong/eureka/internal/pkg/hub"
 	"github.com/coxlong/eureka/internal/service"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ConversationsHandler interface {
 	GetConversation(*gin.Context)
 	GetConversations(*gin.Context)
 	UpdateTitle(*gin.Context)
+	UpdateCollection(*gin.Context)
+	UpdateTags(*gin.Context)
+	UpdatePinned(*gin.Context)
+	UpdateArchived(*gin.Context)
+	Search(*gin.Context)
+	DeleteConversation(*gin.Context)
+	DeleteConversations(*gin.Context)
+	GetTrash(*gin.Context)
+	RestoreConversation(*gin.Context)
+	PurgeConversation(*gin.Context)
 }
 
-func NewConversationHandler(service service.ConversationsService) ConversationsHandler {
-	return &DefaultConversationsHandler{service}
+// NewConversationHandler embedder为nil时语义搜索不可用
+func NewConversationHandler(service service.ConversationsService, knowledgeService service.KnowledgeService, searchService service.SearchService, embedder *embedder, hub *hub.Hub) ConversationsHandler {
+	return &DefaultConversationsHandler{service, knowledgeService, searchService, embedder, hub}
 }
 
 type DefaultConversationsHandler struct {
-	service service.ConversationsService
+	service   service.ConversationsService
+	knowledge service.KnowledgeService
+	search    service.SearchService
+	embedder  *embedder
+	hub       *hub.Hub
 }
 
 func (h *DefaultConversationsHandler) GetConversation(c *gin.Context) {
@@ -39,13 +62,101 @@ func (h *DefaultConversationsHandler) GetConversation(c *gin.Context) {
 	})
 }
 
+const (
+	defaultConversationsLimit = 50
+	maxConversationsLimit     = 200
+)
+
+// pageLimit 返回limit参数，只有cursor时使用默认值，两者都没有时返回0，表示不分页
+func pageLimit(c *gin.Context) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		if c.Query("cursor") == "" {
+			return 0, true
+		}
+		return defaultConversationsLimit, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		c.String(400, "invalid limit")
+		return 0, false
+	}
+	return min(n, maxConversationsLimit), true
+}
+
+// setNextCursor 在X-Next-Cursor响应头中返回下一页的cursor，响应体仍是会话数组
+func setNextCursor(c *gin.Context, next string) {
+	if next != "" {
+		c.Header("X-Next-Cursor", next)
+	}
+}
+
+// GetConversations 返回会话数组，置顶的会话在前。传入limit或cursor时分页，
+// 下一页的cursor在X-Next-Cursor响应头中，没有该响应头时没有下一页。
+// 可以按model、tag、pinned以及更新时间筛选，from和to为UTC日期(2006-01-02)，包含to当天。
+// 归档的会话默认不返回，archived=true时只返回归档的会话，archived=all时都返回
 func (h *DefaultConversationsHandler) GetConversations(c *gin.Context) {
 	user := c.Value(constants.UserSessionKey).(model.User)
-	conversations, err := h.service.GetConversations(user.ID)
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+	filter := model.ConversationFilter{
+		Model: c.Query("model"),
+		Tag:   c.Query("tag"),
+		Limit: limit,
+	}
+	if value := c.Query("pinned"); value != "" {
+		pinned, err := strconv.ParseBool(value)
+		if err != nil {
+			c.String(400, "invalid pinned")
+			return
+		}
+		filter.Pinned = &pinned
+	}
+	switch value := c.Query("archived"); value {
+	case "all":
+	case "":
+		archived := false
+		filter.Archived = &archived
+	default:
+		archived, err := strconv.ParseBool(value)
+		if err != nil {
+			c.String(400, "invalid archived")
+			return
+		}
+		filter.Archived = &archived
+	}
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := model.ParseConversationCursor(value)
+		if err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		filter.After = cursor
+	}
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			c.String(400, "invalid from")
+			return
+		}
+		filter.From = from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			c.String(400, "invalid to")
+			return
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+	conversations, next, err := h.service.GetConversations(user.ID, filter)
 	if err != nil {
 		c.String(400, err.Error())
 		return
 	}
+	setNextCursor(c, next)
 	c.JSON(200, conversations)
 }
 
@@ -65,5 +176,281 @@ func (h *DefaultConversationsHandler) UpdateTitle(c *gin.Context) {
 		c.String(500, err.Error())
 		return
 	}
+	h.hub.Publish(user.ID, "", titleEvent(cid, req.Title))
+	c.String(200, "success")
+}
+
+// UpdateCollection 关联知识库，之后的回答会检索其中的文档，collection_id为空时取消关联
+func (h *DefaultConversationsHandler) UpdateCollection(c *gin.Context) {
+	cid := c.Param("id")
+	user := c.Value(constants.UserSessionKey).(model.User)
+	var req struct {
+		CollectionID string `json:"collection_id"`
+	}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	if req.CollectionID != "" {
+		_, err := h.knowledge.GetCollection(user.ID, req.CollectionID)
+		if errors.Is(err, service.ErrCollectionNotFound) {
+			c.String(400, err.Error())
+			return
+		}
+		if err != nil {
+			c.String(500, err.Error())
+			return
+		}
+	}
+	err = h.service.SetCollection(user.ID, cid, req.CollectionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(404, "conversation not found")
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
+
+func (h *DefaultConversationsHandler) UpdatePinned(c *gin.Context) {
+	cid := c.Param("id")
+	user := c.Value(constants.UserSessionKey).(model.User)
+	var req struct {
+		Pinned bool `json:"pinned"`
+	}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	err = h.service.SetPinned(user.ID, cid, req.Pinned)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(404, "conversation not found")
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
+
+// UpdateArchived 归档的会话不出现在默认的会话列表中，仍然可以打开和继续对话
+func (h *DefaultConversationsHandler) UpdateArchived(c *gin.Context) {
+	cid := c.Param("id")
+	user := c.Value(constants.UserSessionKey).(model.User)
+	var req struct {
+		Archived bool `json:"archived"`
+	}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	err = h.service.SetArchived(user.ID, cid, req.Archived)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(404, "conversation not found")
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
+
+// UpdateTags 替换会话的标签，返回保存后的标签
+func (h *DefaultConversationsHandler) UpdateTags(c *gin.Context) {
+	cid := c.Param("id")
+	user := c.Value(constants.UserSessionKey).(model.User)
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	tags, err := h.service.SetTags(user.ID, cid, req.Tags)
+	if errors.Is(err, service.ErrTagTooLong) || errors.Is(err, service.ErrTooManyTags) {
+		c.String(400, err.Error())
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(404, "conversation not found")
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, map[string]any{
+		"tags": tags,
+	})
+}
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// Search 在用户自己的会话中搜索，q按关键词匹配标题和消息内容，semantic按语义相似度检索消息
+func (h *DefaultConversationsHandler) Search(c *gin.Context) {
+	caller := newCaller(c)
+	limit := defaultSearchLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.String(400, "invalid limit")
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+	if query := strings.TrimSpace(c.Query("q")); query != "" {
+		results, err := h.search.Search(caller.uid, query, limit)
+		if err != nil {
+			c.String(500, err.Error())
+			return
+		}
+		c.JSON(200, results)
+		return
+	}
+	query := strings.TrimSpace(c.Query("semantic"))
+	if query == "" {
+		c.String(400, "q or semantic is required")
+		return
+	}
+	if h.embedder == nil {
+		c.String(400, "semantic search is disabled")
+		return
+	}
+	vectors, err := h.embedder.embed(c, caller, []string{query})
+	if err != nil {
+		eResp := toOpenaiErrorResponse(err)
+		c.String(eResp.Error.HTTPStatusCode, eResp.Error.Message)
+		return
+	}
+	results, err := h.search.SemanticSearch(caller.uid, vectors[0], limit)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, results)
+}
+
+// maxBulkDelete 一次批量删除的最大会话数
+const maxBulkDelete = 100
+
+func conversationsEvent(eventType string, ids []string) hub.Event {
+	return hub.Event{
+		Type: eventType,
+		Data: map[string]any{
+			"conversation_ids": ids,
+		},
+	}
+}
+
+// DeleteConversation 把会话连同消息移入回收站
+func (h *DefaultConversationsHandler) DeleteConversation(c *gin.Context) {
+	cid := c.Param("id")
+	user := c.Value(constants.UserSessionKey).(model.User)
+	deleted, err := h.service.DeleteConversations(user.ID, []string{cid})
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	if len(deleted) == 0 {
+		c.String(404, "conversation not found")
+		return
+	}
+	h.hub.Publish(user.ID, "", conversationsEvent(constants.EventConversationDeleted, deleted))
+	c.String(200, "success")
+}
+
+// DeleteConversations 批量移入回收站，返回实际删除的会话ID，不存在的ID被忽略
+func (h *DefaultConversationsHandler) DeleteConversations(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxBulkDelete {
+		c.String(400, fmt.Sprintf("ids must contain 1 to %d conversations", maxBulkDelete))
+		return
+	}
+	deleted, err := h.service.DeleteConversations(user.ID, req.IDs)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	if len(deleted) > 0 {
+		h.hub.Publish(user.ID, "", conversationsEvent(constants.EventConversationDeleted, deleted))
+	}
+	c.JSON(200, map[string]any{
+		"ids": deleted,
+	})
+}
+
+// GetTrash 返回回收站中的会话，按删除时间降序排列，与GetConversations一样使用limit和cursor分页
+func (h *DefaultConversationsHandler) GetTrash(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+	var after *model.TrashCursor
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := model.ParseTrashCursor(value)
+		if err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		after = cursor
+	}
+	conversations, next, err := h.service.GetTrash(user.ID, after, limit)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	setNextCursor(c, next)
+	c.JSON(200, conversations)
+}
+
+func (h *DefaultConversationsHandler) RestoreConversation(c *gin.Context) {
+	cid := c.Param("id")
+	user := c.Value(constants.UserSessionKey).(model.User)
+	restored, err := h.service.RestoreConversations(user.ID, []string{cid})
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	if len(restored) == 0 {
+		c.String(404, "conversation not found in trash")
+		return
+	}
+	h.hub.Publish(user.ID, "", conversationsEvent(constants.EventConversationRestored, restored))
+	c.String(200, "success")
+}
+
+// PurgeConversation 永久删除回收站中的会话，不能恢复
+func (h *DefaultConversationsHandler) PurgeConversation(c *gin.Context) {
+	cid := c.Param("id")
+	user := c.Value(constants.UserSessionKey).(model.User)
+	purged, err := h.service.PurgeConversations(user.ID, []string{cid})
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	if len(purged) == 0 {
+		c.String(404, "conversation not found in trash")
+		return
+	}
 	c.String(200, "success")
 }
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/constants"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/repository"
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newConversationsRouter 返回使用内存SQLite的会话接口，请求以用户u1的身份发出
+func newConversationsRouter(t *testing.T) (*gin.Engine, service.ConversationsService) {
+	if _, err := log.InitLogger(config.Development, &config.Logger{Filename: t.TempDir() + "/test.log", Level: "error"}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	repo, err := repository.NewGormConversationRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conversationService := service.NewConversationService(repo, nil, &config.Trash{})
+	h := NewConversationHandler(conversationService, nil, nil, nil, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(constants.UserSessionKey, model.User{ID: "u1"}) })
+	r.GET("/conversations", h.GetConversations)
+	r.GET("/trash", h.GetTrash)
+	return r, conversationService
+}
+
+func get(r *gin.Engine, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestGetConversationsCursor(t *testing.T) {
+	r, conversationService := newConversationsRouter(t)
+	for _, id := range []string{"c1", "c2", "c3"} {
+		if err := conversationService.CreateConversation("u1", &model.ConversationMeta{ID: id}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := conversationService.DeleteConversations("u1", []string{"c3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 逐页读取直到没有X-Next-Cursor
+	var ids []string
+	target := "/conversations?limit=1"
+	for i := 0; i < 5; i++ {
+		w := get(r, target)
+		if w.Code != 200 {
+			t.Fatalf("got %d %s", w.Code, w.Body)
+		}
+		var page []model.ConversationMeta
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page {
+			ids = append(ids, item.ID)
+		}
+		next := w.Header().Get("X-Next-Cursor")
+		if next == "" {
+			break
+		}
+		target = "/conversations?limit=1&cursor=" + url.QueryEscape(next)
+	}
+	if got := strings.Join(ids, ","); got != "c2,c1" {
+		t.Fatalf("got %s, want c2,c1", got)
+	}
+
+	encode := func(value string) string {
+		return url.QueryEscape(base64.RawURLEncoding.EncodeToString([]byte(value)))
+	}
+	now := time.Now().Format(time.RFC3339Nano)
+	malformed := []string{
+		url.QueryEscape("not base64!"),
+		encode("c1"),
+		encode("1|" + now),
+		encode("1|" + now + "|"),
+		encode("yes|" + now + "|c1"),
+		encode("1|yesterday|c1"),
+	}
+	for _, cursor := range malformed {
+		if w := get(r, "/conversations?cursor="+cursor); w.Code != 400 || w.Body.String() != model.ErrInvalidCursor.Error() {
+			t.Errorf("conversations cursor %s: got %d %s, want 400", cursor, w.Code, w.Body)
+		}
+		if w := get(r, "/trash?cursor="+cursor); w.Code != 400 {
+			t.Errorf("trash cursor %s: got %d %s, want 400", cursor, w.Code, w.Body)
+		}
+	}
+	// 会话列表和回收站的cursor不能混用
+	if w := get(r, "/trash?cursor="+encode("0|"+now+"|c1")); w.Code != 400 {
+		t.Errorf("conversation cursor in trash: got %d, want 400", w.Code)
+	}
+	if w := get(r, "/conversations?cursor="+encode(now+"|c1")); w.Code != 400 {
+		t.Errorf("trash cursor in conversations: got %d, want 400", w.Code)
+	}
+	if w := get(r, "/trash?cursor="+encode(now+"|c1")); w.Code != 200 {
+		t.Errorf("trash cursor: got %d %s, want 200", w.Code, w.Body)
+	}
+	if w := get(r, "/conversations?limit=0"); w.Code != 400 {
+		t.Errorf("limit=0: got %d, want 400", w.Code)
+	}
+}
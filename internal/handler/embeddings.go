@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+)
+
+const defaultEmbeddingModel = "text-embedding-3-small"
+
+// embedder 使用配置的embedding模型计算向量，与聊天请求一样按路由表选择上游、
+// 检查限额、查找密钥并记录用量
+type embedder struct {
+	registry     *provider.Registry
+	keysService  service.KeysService
+	usageService service.UsageService
+	quotaService service.QuotaService
+	retry        provider.RetryPolicy
+	quota        config.Quota
+	model        string
+}
+
+func newEmbedder(registry *provider.Registry, keysService service.KeysService, usageService service.UsageService, quotaService service.QuotaService, cfg *config.OpenAI) *embedder {
+	e := &embedder{
+		registry:     registry,
+		keysService:  keysService,
+		usageService: usageService,
+		quotaService: quotaService,
+		retry:        provider.NewRetryPolicy(&cfg.Retry),
+		quota:        cfg.Quota,
+		model:        cfg.EmbeddingModel,
+	}
+	if e.model == "" {
+		e.model = defaultEmbeddingModel
+	}
+	return e
+}
+
+// checkQuota 与聊天请求一样计入用户的总限额和该模型的限额，超出时返回429。
+// 每日token按输入文本估算
+func (e *embedder) checkQuota(caller caller, route *config.Model, inputs []string) (*model.QuotaStatus, error) {
+	promptTokens := 0
+	if tok, err := tokenizer.ForModel(route.ModelID, route.Encoding); err == nil {
+		for _, input := range inputs {
+			promptTokens += tok.Count(input)
+		}
+	}
+	return checkRouteQuota(e.quotaService, e.quota, caller.uid, route, promptTokens)
+}
+
+// create 按路由调用上游计算向量并记录用量，返回的向量按输入顺序排列
+func (e *embedder) create(ctx context.Context, caller caller, route *config.Model, req provider.EmbeddingRequest) (provider.EmbeddingResponse, error) {
+	response, upstream, err := provider.Failover(ctx, e.retry, provider.Upstreams(route), func(ctx context.Context, upstream config.Upstream) (provider.EmbeddingResponse, error) {
+		key, err := resolveAPIKey(e.keysService, caller, &upstream)
+		if err != nil {
+			return provider.EmbeddingResponse{}, err
+		}
+		client, err := provider.New(upstream.Provider, upstream.BaseURL, key)
+		if err != nil {
+			return provider.EmbeddingResponse{}, err
+		}
+		upstreamReq := req
+		upstreamReq.Model = upstream.ModelID
+		return provider.CreateEmbeddings(ctx, client, upstreamReq)
+	})
+	if err != nil {
+		return response, err
+	}
+	recordUsage(e.usageService, caller.uid, "", "", route, upstream, response.Usage)
+	// 部分服务商不保证按输入顺序返回
+	sort.Slice(response.Data, func(i, j int) bool {
+		return response.Data[i].Index < response.Data[j].Index
+	})
+	return response, nil
+}
+
+// embed 使用配置的embedding模型计算inputs的向量，返回的向量与输入一一对应。
+// 知识库检索、语义搜索和后台索引都计入caller的限额
+func (e *embedder) embed(ctx context.Context, caller caller, inputs []string) ([][]float32, error) {
+	route, ok := e.registry.Resolve(e.model)
+	if !ok {
+		return nil, fmt.Errorf("embedding model %s does not exist", e.model)
+	}
+	if _, err := e.checkQuota(caller, route, inputs); err != nil {
+		return nil, err
+	}
+	response, err := e.create(ctx, caller, route, provider.EmbeddingRequest{
+		Input: inputs,
+		User:  caller.uid,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Data) != len(inputs) {
+		return nil, fmt.Errorf("embedding model returned %d vectors for %d inputs", len(response.Data), len(inputs))
+	}
+	vectors := make([][]float32, len(response.Data))
+	for i, item := range response.Data {
+		vectors[i] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// embedFunc 返回以caller身份计算向量的service.EmbedFunc，用于后台任务
+func (e *embedder) embedFunc(caller caller) service.EmbedFunc {
+	return func(ctx context.Context, inputs []string) ([][]float32, error) {
+		return e.embed(ctx, caller, inputs)
+	}
+}
+
+type EmbeddingsHandler interface {
+	Create(*gin.Context)
+}
+
+func NewEmbeddingsHandler(embedder *embedder) EmbeddingsHandler {
+	return &DefaultEmbeddingsHandler{embedder}
+}
+
+type DefaultEmbeddingsHandler struct {
+	embedder *embedder
+}
+
+// EmbeddingRequest 与OpenAI的/embeddings请求一致，input为字符串或字符串数组
+type EmbeddingRequest struct {
+	Input          json.RawMessage `json:"input"`
+	Model          string          `json:"model"`
+	User           string          `json:"user"`
+	EncodingFormat string          `json:"encoding_format"`
+	Dimensions     int             `json:"dimensions"`
+}
+
+// inputs 返回input中的文本，不支持token数组形式的输入
+func (req *EmbeddingRequest) inputs() ([]string, error) {
+	var input string
+	if err := json.Unmarshal(req.Input, &input); err == nil {
+		return []string{input}, nil
+	}
+	var inputs []string
+	if err := json.Unmarshal(req.Input, &inputs); err == nil && len(inputs) > 0 {
+		return inputs, nil
+	}
+	return nil, errors.New("input must be a string or a non-empty array of strings")
+}
+
+type embeddingData struct {
+	Object string `json:"object"`
+	// Embedding encoding_format为base64时是小端序float32数组的base64编码
+	Embedding any `json:"embedding"`
+	Index     int `json:"index"`
+}
+
+// Create 按路由表把请求转发给上游，与/chat/completions一样检查限额、查找密钥、切换上游并记录用量
+func (h *DefaultEmbeddingsHandler) Create(c *gin.Context) {
+	var req EmbeddingRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(400, openai.ErrorResponse{
+			Error: &openai.APIError{
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+	inputs, err := req.inputs()
+	if err != nil {
+		c.JSON(400, openai.ErrorResponse{
+			Error: &openai.APIError{
+				HTTPStatusCode: 400,
+				Type:           "invalid_request_error",
+				Message:        err.Error(),
+			},
+		})
+		return
+	}
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		c.JSON(400, openai.ErrorResponse{
+			Error: &openai.APIError{
+				HTTPStatusCode: 400,
+				Type:           "invalid_request_error",
+				Message:        fmt.Sprintf("invalid encoding_format %s", req.EncodingFormat),
+			},
+		})
+		return
+	}
+	route, ok := h.embedder.registry.Resolve(req.Model)
+	if !ok {
+		c.JSON(404, openai.ErrorResponse{
+			Error: &openai.APIError{
+				HTTPStatusCode: 404,
+				Code:           "model_not_found",
+				Type:           "invalid_request_error",
+				Message:        fmt.Sprintf("The model `%s` does not exist", req.Model),
+			},
+		})
+		return
+	}
+	caller := newCaller(c)
+	status, err := h.embedder.checkQuota(caller, route, inputs)
+	setRateLimitHeaders(c, status)
+	if err != nil {
+		eResp := toOpenaiErrorResponse(err)
+		c.JSON(eResp.Error.HTTPStatusCode, eResp)
+		return
+	}
+	user := req.User
+	if user == "" {
+		user = caller.uid
+	}
+	response, err := h.embedder.create(c, caller, route, provider.EmbeddingRequest{
+		Input:      inputs,
+		User:       user,
+		Dimensions: req.Dimensions,
+	})
+	if err != nil {
+		eResp := toOpenaiErrorResponse(err)
+		c.JSON(eResp.Error.HTTPStatusCode, eResp)
+		return
+	}
+	data := make([]embeddingData, len(response.Data))
+	for i, item := range response.Data {
+		data[i] = embeddingData{Object: "embedding", Embedding: item.Embedding, Index: item.Index}
+		if req.EncodingFormat == "base64" {
+			raw := make([]byte, len(item.Embedding)*4)
+			for j, v := range item.Embedding {
+				binary.LittleEndian.PutUint32(raw[j*4:], math.Float32bits(v))
+			}
+			data[i].Embedding = base64.StdEncoding.EncodeToString(raw)
+		}
+	}
+	c.JSON(200, map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  route.Name,
+		"usage":  response.Usage,
+	})
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const finishReasonCancelled openai.FinishReason = "cancelled"
+
+const (
+	// detachedTimeout detach模式下单次生成的最长时间
+	detachedTimeout = 10 * time.Minute
+	// brokerTTL 回答生成结束后事件缓存的保留时间，需大于detachedTimeout
+	brokerTTL  = 30 * time.Minute
+	maxBrokers = 1024
+)
+
+// generate 读取上游的流式响应并发布到broker，结束后按需保存回答。模型调用启用的
+// 服务端工具时执行工具并发出tool事件，再以工具结果继续请求上游，直到得到最终回答。
+// 该方法在独立的goroutine中运行，不能再使用请求的gin.Context
+func (h *DefaultChatHandler) generate(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	stream provider.Stream,
+	route *config.Model,
+	upstream config.Upstream,
+	req *ChatCompletionRequest,
+	uid, answerID string,
+	broker *answerBroker,
+) {
+	defer cancel()
+
+	var usage openai.Usage
+	var sorted []model.Message
+	status := model.MessageStatusIncomplete
+	for iteration := 0; ; iteration++ {
+		var answers map[int]*model.Message
+		answers, status = h.readStream(stream, upstream, req, answerID, broker)
+		stream.Close()
+		// 流式响应没有usage，按发出的prompt和收到的内容在本地计算
+		sorted = sortAnswers(answers)
+		if len(sorted) > 0 {
+			usage = addUsage(usage, accountAnswers(route, req.promptTokens, openai.Usage{}, sorted))
+		}
+		if status != model.MessageStatusFinished || iteration >= h.maxToolIterations ||
+			len(sorted) != 1 || !req.runsServerTools(sorted[0].ToolCalls) {
+			break
+		}
+
+		steps := h.runTools(ctx, req, route, sorted[0])
+		broker.publish(eventToolSteps, marshalSteps(steps))
+		sorted = nil
+		var err error
+		stream, upstream, err = h.openStream(ctx, req, route)
+		if err != nil && broker.isCancelled() {
+			status = model.MessageStatusCancelled
+			broker.publish("", marshalCancelled(req, answerID, nil))
+			broker.publish("", []byte("[DONE]"))
+			break
+		}
+		if err != nil {
+			status = model.MessageStatusIncomplete
+			log.Warn("continue after tool calls failed", zap.String("answer_id", answerID), zap.Error(err))
+			broker.publish("error", marshalError(err))
+			break
+		}
+	}
+	if status == model.MessageStatusFinished {
+		broker.publish("", []byte("[DONE]"))
+	}
+
+	created := req.ID == ""
+	saved := false
+	if req.Save {
+		for i := range sorted {
+			sorted[i].Status = status
+		}
+		if err := h.save(uid, req, sorted); err != nil {
+			log.Error("save failed", zap.Error(err))
+		} else {
+			saved = true
+		}
+	}
+	// 保存后立即结束流，标题在后台生成并通过hub推送，不占用客户端的连接
+	broker.close()
+	if saved && created && status == model.MessageStatusFinished && len(sorted) > 0 {
+		h.autoTitleAsync(req, sorted[0])
+	}
+	if usage.TotalTokens > 0 {
+		h.recordUsage(uid, req.ID, answerID, route, upstream, usage)
+	}
+}
+
+// readStream 读取一次上游的流式响应，按choice的index分别累积内容和工具调用，
+// n > 1时每个choice保存为一个分支。只有读到上游的结束标志才认为回答是完整的，
+// 出错或客户端断开时为incomplete
+func (h *DefaultChatHandler) readStream(
+	stream provider.Stream,
+	upstream config.Upstream,
+	req *ChatCompletionRequest,
+	answerID string,
+	broker *answerBroker,
+) (map[int]*model.Message, string) {
+	answers := map[int]*model.Message{}
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return answers, model.MessageStatusFinished
+		}
+		if err != nil && broker.isCancelled() {
+			broker.publish("", marshalCancelled(req, answerID, answers))
+			broker.publish("", []byte("[DONE]"))
+			return answers, model.MessageStatusCancelled
+		}
+		if err != nil {
+			log.Warn("stream interrupted", zap.String("answer_id", answerID), zap.Error(err))
+			broker.publish("error", marshalError(err))
+			return answers, model.MessageStatusIncomplete
+		}
+		for _, choice := range response.Choices {
+			answer, ok := answers[choice.Index]
+			if !ok {
+				answer = &model.Message{
+					ID:        answerID,
+					Parent:    req.CurrentNodeID,
+					Role:      openai.ChatMessageRoleAssistant,
+					Citations: req.citations,
+					Upstream:  provider.UpstreamName(upstream),
+				}
+				if choice.Index != 0 {
+					answer.ID = uuid.NewString()
+				}
+				answers[choice.Index] = answer
+			}
+			answer.Content += choice.Delta.Content
+			answer.ToolCalls = appendToolCallDeltas(answer.ToolCalls, choice.Delta.ToolCalls)
+			if delta := choice.Delta.FunctionCall; delta != nil {
+				if answer.FunctionCall == nil {
+					answer.FunctionCall = &model.FunctionCall{}
+				}
+				answer.FunctionCall.Name += delta.Name
+				answer.FunctionCall.Arguments += delta.Arguments
+			}
+		}
+		response.ID = answerID
+		rByte, err := json.Marshal(response)
+		if err != nil {
+			broker.publish("error", marshalError(err))
+			return answers, model.MessageStatusIncomplete
+		}
+		broker.publish("", rByte)
+	}
+}
+
+// appendToolCallDeltas 按index合并流式响应中分片的工具调用
+func appendToolCallDeltas(calls []model.ToolCall, deltas []openai.ToolCall) []model.ToolCall {
+	for i, delta := range deltas {
+		index := i
+		if delta.Index != nil {
+			index = *delta.Index
+		}
+		for len(calls) <= index {
+			calls = append(calls, model.ToolCall{})
+		}
+		call := &calls[index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = string(delta.Type)
+		}
+		call.Function.Name += delta.Function.Name
+		call.Function.Arguments += delta.Function.Arguments
+	}
+	return calls
+}
+
+// sortAnswers 按choice的index排序，保证index为0的回答排在首位
+func sortAnswers(answers map[int]*model.Message) []model.Message {
+	indexes := make([]int, 0, len(answers))
+	for index := range answers {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	result := make([]model.Message, 0, len(indexes))
+	for _, index := range indexes {
+		result = append(result, *answers[index])
+	}
+	return result
+}
+
+// followSSE 把broker中from之后的事件写给客户端，直到生成结束或客户端断开。
+// 每个事件的id为其序号(从1开始)，客户端重连时通过Last-Event-ID从断点继续
+func followSSE(c *gin.Context, broker *answerBroker, from int) {
+	broker.follow(c.Request.Context(), from, func(id int, event streamEvent) error {
+		writeSSE(c.Writer, id, event)
+		c.Writer.Flush()
+		return nil
+	})
+}
+
+func writeSSE(w io.Writer, id int, event streamEvent) {
+	w.Write([]byte("id: " + strconv.Itoa(id) + "\n"))
+	if event.Event != "" {
+		w.Write([]byte("event: " + event.Event + "\n"))
+	}
+	w.Write([]byte("data: "))
+	w.Write(event.Data)
+	w.Write([]byte("\n\n"))
+}
+
+// marshalCancelled 生成取消时的最后一个数据块，每个choice的finish_reason均为cancelled
+func marshalCancelled(req *ChatCompletionRequest, answerID string, answers map[int]*model.Message) []byte {
+	response := openai.ChatCompletionStreamResponse{
+		ID:      answerID,
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+	indexes := []int{0}
+	if len(answers) > 0 {
+		indexes = indexes[:0]
+		for index := range answers {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+	}
+	for _, index := range indexes {
+		response.Choices = append(response.Choices, openai.ChatCompletionStreamChoice{
+			Index:        index,
+			FinishReason: finishReasonCancelled,
+		})
+	}
+	rByte, _ := json.Marshal(response)
+	return rByte
+}
+
+// marshalError 生成error事件的数据，格式为openai.ErrorResponse
+func marshalError(err error) []byte {
+	rByte, _ := json.Marshal(toOpenaiErrorResponse(err))
+	return rByte
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/service"
+	"go.uber.org/zap"
+)
+
+const (
+	indexQueueSize  = 256
+	indexJobTimeout = time.Minute
+)
+
+// indexJob 一次保存的消息，caller用于查找计算向量使用的密钥
+type indexJob struct {
+	caller   caller
+	cid      string
+	messages []model.Message
+}
+
+// messageIndexer 在后台计算保存的消息的向量，供语义搜索使用。
+// 单个worker按顺序处理，队列满时丢弃新的任务，这些消息不会出现在语义搜索结果中
+type messageIndexer struct {
+	search   service.SearchService
+	embedder *embedder
+	jobs     chan indexJob
+}
+
+func newMessageIndexer(search service.SearchService, embedder *embedder) *messageIndexer {
+	i := &messageIndexer{
+		search:   search,
+		embedder: embedder,
+		jobs:     make(chan indexJob, indexQueueSize),
+	}
+	go i.run()
+	return i
+}
+
+// enqueue 提交保存的消息，indexer为nil(未开启语义搜索)时忽略
+func (i *messageIndexer) enqueue(caller caller, cid string, messages []model.Message) {
+	if i == nil || len(messages) == 0 {
+		return
+	}
+	select {
+	case i.jobs <- indexJob{caller, cid, messages}:
+	default:
+		log.Warn("message index queue is full", zap.String("conversation_id", cid))
+	}
+}
+
+func (i *messageIndexer) run() {
+	for job := range i.jobs {
+		ctx, cancel := context.WithTimeout(context.Background(), indexJobTimeout)
+		err := i.search.IndexMessages(ctx, job.caller.uid, job.cid, job.messages, i.embedder.embedFunc(job.caller))
+		cancel()
+		if err != nil {
+			log.Warn("index messages failed", zap.String("conversation_id", job.cid), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+var providerPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+type KeysHandler interface {
+	GetKeys(*gin.Context)
+	SaveKey(*gin.Context)
+	DeleteKey(*gin.Context)
+}
+
+func NewKeysHandler(service service.KeysService, admins []string) KeysHandler {
+	return &DefaultKeysHandler{service, newAdminSet(admins)}
+}
+
+type DefaultKeysHandler struct {
+	service service.KeysService
+	admins  adminSet
+}
+
+func (h *DefaultKeysHandler) GetKeys(c *gin.Context) {
+	uid, ok := h.admins.targetUID(c)
+	if !ok {
+		return
+	}
+	keys, err := h.service.GetKeys(uid)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, keys)
+}
+
+// SaveKey 保存用户的密钥，provider为服务商类型或上游配置的KeyName
+func (h *DefaultKeysHandler) SaveKey(c *gin.Context) {
+	uid, ok := h.admins.targetUID(c)
+	if !ok {
+		return
+	}
+	provider := c.Param("provider")
+	if !providerPattern.MatchString(provider) {
+		c.String(400, "invalid provider")
+		return
+	}
+	var req struct {
+		Key string `json:"key" binding:"required"`
+	}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	err = h.service.SaveKey(uid, provider, req.Key)
+	if errors.Is(err, service.ErrVaultDisabled) {
+		c.String(400, err.Error())
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
+
+func (h *DefaultKeysHandler) DeleteKey(c *gin.Context) {
+	uid, ok := h.admins.targetUID(c)
+	if !ok {
+		return
+	}
+	err := h.service.DeleteKey(uid, c.Param("provider"))
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/constants"
+	"github.com/coxlong/eureka/internal/pkg/document"
+	"github.com/coxlong/eureka/internal/pkg/hub"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const (
+	knowledgePrompt = "Answer using the numbered excerpts from the user's documents below when they are relevant, and cite them inline as [n]. If the excerpts do not contain the answer, say so or answer from general knowledge without citing them."
+	// citationSnippetLength 引用中保存的分块开头的字符数
+	citationSnippetLength = 200
+	indexTimeout          = 10 * time.Minute
+	// eventCitations 流式回答开始前发出的事件，数据为检索到的引用
+	eventCitations = "citations"
+)
+
+// retrieveKnowledge 会话关联知识库时，按最后一条用户消息检索相关分块，以编号摘录的形式
+// 作为system消息插入到该消息之前。回答引用的分块保存在req.citations中
+func (h *DefaultChatHandler) retrieveKnowledge(ctx context.Context, req *ChatCompletionRequest) error {
+	collectionID := req.CollectionID
+	if collectionID == "" && req.ID != "" {
+		// 会话不存在时由保存时报错，这里不检索
+		if meta, _, err := h.service.GetConversation(req.ID, req.caller.uid); err == nil {
+			collectionID = meta.CollectionID
+		}
+	}
+	if collectionID == "" {
+		return nil
+	}
+	messages := req.ChatCompletionRequest.Messages
+	last := len(messages) - 1
+	for last >= 0 && messages[last].Role != openai.ChatMessageRoleUser {
+		last--
+	}
+	if last < 0 {
+		return nil
+	}
+	query := strings.TrimSpace(provider.MessageText(messages[last]))
+	if query == "" {
+		return nil
+	}
+	vectors, err := h.embedder.embed(ctx, req.caller, []string{query})
+	if err != nil {
+		return err
+	}
+	chunks, err := h.knowledge.Retrieve(req.caller.uid, collectionID, vectors[0])
+	if errors.Is(err, service.ErrCollectionNotFound) {
+		return &openai.APIError{
+			HTTPStatusCode: 400,
+			Type:           "invalid_request_error",
+			Message:        fmt.Sprintf("collection %s does not exist", collectionID),
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(knowledgePrompt)
+	req.citations = make([]model.Citation, len(chunks))
+	for i, chunk := range chunks {
+		fmt.Fprintf(&prompt, "\n\n[%d] %s\n%s", i+1, chunk.DocumentName, chunk.Content)
+		snippet := []rune(chunk.Content)
+		req.citations[i] = model.Citation{
+			Index:        i + 1,
+			DocumentID:   chunk.DocumentID,
+			DocumentName: chunk.DocumentName,
+			ChunkID:      chunk.ID,
+			Score:        chunk.Score,
+			Snippet:      string(snippet[:min(len(snippet), citationSnippetLength)]),
+		}
+	}
+	req.ChatCompletionRequest.Messages = append(messages[:last:last], append([]openai.ChatCompletionMessage{{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: prompt.String(),
+	}}, messages[last:]...)...)
+	return nil
+}
+
+type KnowledgeHandler interface {
+	CreateCollection(*gin.Context)
+	GetCollections(*gin.Context)
+	DeleteCollection(*gin.Context)
+	GetDocuments(*gin.Context)
+	UploadDocument(*gin.Context)
+	DeleteDocument(*gin.Context)
+}
+
+func NewKnowledgeHandler(service service.KnowledgeService, embedder *embedder, hub *hub.Hub) KnowledgeHandler {
+	return &DefaultKnowledgeHandler{service, embedder, hub}
+}
+
+type DefaultKnowledgeHandler struct {
+	service  service.KnowledgeService
+	embedder *embedder
+	hub      *hub.Hub
+}
+
+func (h *DefaultKnowledgeHandler) CreateCollection(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	collection := &model.Collection{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := h.service.CreateCollection(user.ID, collection); err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, collection)
+}
+
+func (h *DefaultKnowledgeHandler) GetCollections(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	collections, err := h.service.GetCollections(user.ID)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, collections)
+}
+
+func (h *DefaultKnowledgeHandler) DeleteCollection(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	err := h.service.DeleteCollection(user.ID, c.Param("id"))
+	if errors.Is(err, service.ErrCollectionNotFound) {
+		c.String(404, err.Error())
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
+
+func (h *DefaultKnowledgeHandler) GetDocuments(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	documents, err := h.service.GetDocuments(user.ID, c.Param("id"))
+	if errors.Is(err, service.ErrCollectionNotFound) {
+		c.String(404, err.Error())
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, documents)
+}
+
+// UploadDocument 接收multipart表单中的file字段，提取文本和分块后立即返回202，
+// 向量在后台计算，完成后文档状态变为ready并通过hub通知
+func (h *DefaultKnowledgeHandler) UploadDocument(c *gin.Context) {
+	caller := newCaller(c)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	defer reader.Close()
+	doc, chunks, err := h.service.AddDocument(caller.uid, c.Param("id"), file.Filename, reader)
+	switch {
+	case errors.Is(err, service.ErrCollectionNotFound):
+		c.String(404, err.Error())
+		return
+	case errors.Is(err, service.ErrDocumentTooLarge):
+		c.String(413, err.Error())
+		return
+	case errors.Is(err, document.ErrUnsupportedType):
+		c.String(415, err.Error())
+		return
+	case errors.Is(err, document.ErrNoText):
+		c.String(422, err.Error())
+		return
+	case err != nil:
+		c.String(500, err.Error())
+		return
+	}
+
+	go func(doc model.Document) {
+		ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+		defer cancel()
+		if err := h.service.IndexDocument(ctx, &doc, chunks, h.embedder.embedFunc(caller)); err != nil {
+			log.Warn("index document failed", zap.String("document_id", doc.ID), zap.Error(err))
+		}
+		h.hub.Publish(caller.uid, "", hub.Event{
+			Type: constants.EventDocumentStatus,
+			Data: doc,
+		})
+	}(*doc)
+	c.JSON(202, doc)
+}
+
+func (h *DefaultKnowledgeHandler) DeleteDocument(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	err := h.service.DeleteDocument(user.ID, c.Param("documentID"))
+	if errors.Is(err, service.ErrDocumentNotFound) {
+		c.String(404, err.Error())
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
@@ -2,19 +2,48 @@ package handler
 
 import (
 	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/hub"
+	"github.com/coxlong/eureka/internal/provider"
 	"github.com/coxlong/eureka/internal/service"
+	"github.com/coxlong/eureka/internal/tool"
 )
 
 type Manager struct {
 	Auth          AuthHandler
 	Chat          ChatHandler
 	Conversations ConversationsHandler
+	Keys          KeysHandler
+	Models        ModelsHandler
+	Settings      SettingsHandler
+	Usage         UsageHandler
+	Quota         QuotaHandler
+	Tools         ToolsHandler
+	Attachments   AttachmentsHandler
+	Knowledge     KnowledgeHandler
+	Embeddings    EmbeddingsHandler
 }
 
-func NewManager(cfg *config.Config, conversationsService service.ConversationsService) *Manager {
+func NewManager(cfg *config.Config, conversationsService service.ConversationsService, keysService service.KeysService, settingsService service.SettingsService, usageService service.UsageService, quotaService service.QuotaService, attachmentsService service.AttachmentsService, knowledgeService service.KnowledgeService, searchService service.SearchService) *Manager {
+	registry := provider.NewRegistry(&cfg.OpenAI)
+	eventHub := hub.New()
+	toolRegistry := tool.NewRegistry(&cfg.Tools)
+	embedder := newEmbedder(registry, keysService, usageService, quotaService, &cfg.OpenAI)
+	searchEmbedder := embedder
+	if cfg.Search.DisableSemantic {
+		searchEmbedder = nil
+	}
 	return &Manager{
 		Auth:          NewDefaultAuthHandler(cfg.Authorization.GithubClient, cfg.Authorization.GithubClientSecret, cfg.Env.FrontendAddr),
-		Chat:          NewChatHandler(conversationsService, cfg.OpenAI.BaseURL),
-		Conversations: NewConversationHandler(conversationsService),
+		Chat:          NewChatHandler(conversationsService, keysService, settingsService, usageService, quotaService, attachmentsService, knowledgeService, searchService, registry, toolRegistry, eventHub, &cfg.OpenAI, &cfg.Tools, &cfg.Search, cfg.Env.FrontendAddr),
+		Conversations: NewConversationHandler(conversationsService, knowledgeService, searchService, searchEmbedder, eventHub),
+		Keys:          NewKeysHandler(keysService, cfg.Authorization.Admins),
+		Models:        NewModelsHandler(registry),
+		Settings:      NewSettingsHandler(settingsService),
+		Usage:         NewUsageHandler(usageService, cfg.Authorization.Admins),
+		Quota:         NewQuotaHandler(quotaService, &cfg.OpenAI, cfg.Authorization.Admins),
+		Tools:         NewToolsHandler(toolRegistry),
+		Attachments:   NewAttachmentsHandler(attachmentsService),
+		Embeddings:    NewEmbeddingsHandler(embedder),
+		Knowledge:     NewKnowledgeHandler(knowledgeService, embedder, eventHub),
 	}
 }
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const defaultMemoryBudget = 4000
+
+// compactHistory 用摘要代替已被覆盖的历史消息。摘要之后的消息超过token预算时，
+// 把除最近消息以外的部分与旧摘要合并为新的摘要节点并保存，摘要失败时退回使用原文
+func (h *DefaultChatHandler) compactHistory(ctx context.Context, req *ChatCompletionRequest, route *config.Model, history []model.Message, summary *model.Message) []openai.ChatCompletionMessage {
+	previous := ""
+	start := 0
+	if summary != nil {
+		previous = summary.Content
+		for i, item := range history {
+			if item.ID == summary.Parent {
+				start = i + 1
+				break
+			}
+		}
+	}
+	// 被摘要覆盖的system消息仍然原样发送
+	var system []model.Message
+	for _, item := range history[:start] {
+		if item.Role == openai.ChatMessageRoleSystem {
+			system = append(system, item)
+		}
+	}
+	recent := history[start:]
+
+	tok, err := tokenizer.ForModel(route.ModelID, route.Encoding)
+	if err != nil {
+		log.Warn("load tokenizer failed", zap.Error(err))
+		return buildMemoryPrompt(system, previous, recent)
+	}
+	if tok.CountMessages(buildMemoryPrompt(system, previous, recent)) > h.memory.TokenBudget {
+		if cut := memoryCut(tok, recent, h.memory.RecentTokens); cut >= 0 {
+			content, upstream, err := h.summarize(ctx, req.caller, route, previous, toOpenaiMessages(recent[:cut+1]))
+			if err != nil {
+				log.Warn("summarize history failed", zap.Error(err))
+				return buildMemoryPrompt(system, previous, recent)
+			}
+			node := &model.Message{
+				ID:       uuid.NewString(),
+				Parent:   recent[cut].ID,
+				Content:  content,
+				Upstream: provider.UpstreamName(upstream),
+				Status:   model.MessageStatusFinished,
+			}
+			if err := h.service.SaveSummary(req.caller.uid, req.ID, node); err != nil {
+				log.Error("save summary failed", zap.Error(err))
+			}
+			for _, item := range recent[:cut+1] {
+				if item.Role == openai.ChatMessageRoleSystem {
+					system = append(system, item)
+				}
+			}
+			previous = content
+			recent = recent[cut+1:]
+		}
+	}
+	return buildMemoryPrompt(system, previous, recent)
+}
+
+// memoryCut 返回需要被摘要的最后一条消息的下标，其后的消息总数不超过keep个token。
+// 返回-1表示没有可以摘要的消息
+func memoryCut(tok *tokenizer.Tokenizer, messages []model.Message, keep int) int {
+	total := 0
+	cut := len(messages) - 1
+	for ; cut >= 0; cut-- {
+		total += tok.CountMessage(toOpenaiMessages(messages[cut : cut+1])[0])
+		if total > keep {
+			break
+		}
+	}
+	// 工具调用的结果与发起调用的assistant消息一起摘要
+	for cut >= 0 && cut+1 < len(messages) && messages[cut+1].Role == openai.ChatMessageRoleTool {
+		cut++
+	}
+	// 至少保留一条原文
+	if cut >= len(messages)-1 {
+		cut = len(messages) - 2
+	}
+	return cut
+}
+
+func buildMemoryPrompt(system []model.Message, summary string, recent []model.Message) []openai.ChatCompletionMessage {
+	prompt := toOpenaiMessages(system)
+	if summary != "" {
+		prompt = append(prompt, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: summaryPrefix + summary,
+		})
+	}
+	return append(prompt, toOpenaiMessages(recent)...)
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/gin-gonic/gin"
+)
+
+type ModelsHandler interface {
+	GetModels(*gin.Context)
+}
+
+func NewModelsHandler(registry *provider.Registry) ModelsHandler {
+	return &DefaultModelsHandler{registry}
+}
+
+type DefaultModelsHandler struct {
+	registry *provider.Registry
+}
+
+type ModelInfo struct {
+	ID          string  `json:"id"`
+	Object      string  `json:"object"`
+	OwnedBy     string  `json:"owned_by"`
+	MaxTokens   int     `json:"max_tokens,omitempty"`
+	Temperature float32 `json:"temperature,omitempty"`
+}
+
+// GetModels 返回路由表中的模型，上游地址和密钥等信息不对外暴露
+func (h *DefaultModelsHandler) GetModels(c *gin.Context) {
+	models := []ModelInfo{}
+	for _, item := range h.registry.Models() {
+		models = append(models, ModelInfo{
+			ID:          item.Name,
+			Object:      "model",
+			OwnedBy:     item.Provider,
+			MaxTokens:   item.MaxTokens,
+			Temperature: item.Temperature,
+		})
+	}
+	c.JSON(200, map[string]any{
+		"object": "list",
+		"data":   models,
+	})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/sashabaranov/go-openai"
+)
+
+// precheckQuota 在重建上下文、检索知识库和生成摘要之前检查限额但不计入本次请求，
+// 被拒绝的请求不做这些处理。每日token按请求中的消息估算，客户端只发送新消息时偏小，
+// 由checkQuota按最终的prompt再次检查
+func (h *DefaultChatHandler) precheckQuota(req *ChatCompletionRequest, route *config.Model) error {
+	promptTokens := 0
+	if tok, err := tokenizer.ForModel(route.ModelID, route.Encoding); err == nil {
+		promptTokens = tok.CountMessages(req.ChatCompletionRequest.Messages)
+	}
+	status, err := h.quotaService.Peek(req.caller.uid, quotaLimits(h.quota, route), promptTokens)
+	req.quota = status
+	return quotaError(err)
+}
+
+// checkQuota 计入本次请求，按截断后发给上游的prompt检查每日token，在调用上游之前调用
+func (h *DefaultChatHandler) checkQuota(req *ChatCompletionRequest, route *config.Model) error {
+	status, err := checkRouteQuota(h.quotaService, h.quota, req.caller.uid, route, req.promptTokens)
+	req.quota = status
+	return err
+}
+
+// checkRouteQuota 计入一次对route的请求，检查global中的总限额和route的限额，
+// 超出时返回429的openai.APIError
+func checkRouteQuota(quotaService service.QuotaService, global config.Quota, uid string, route *config.Model, promptTokens int) (*model.QuotaStatus, error) {
+	status, err := quotaService.Check(uid, quotaLimits(global, route), promptTokens)
+	return status, quotaError(err)
+}
+
+func quotaLimits(global config.Quota, route *config.Model) []model.QuotaLimit {
+	return []model.QuotaLimit{
+		{
+			RequestsPerMinute: global.RequestsPerMinute,
+			TokensPerDay:      global.TokensPerDay,
+		},
+		{
+			Model:             route.Name,
+			RequestsPerMinute: route.Quota.RequestsPerMinute,
+			TokensPerDay:      route.Quota.TokensPerDay,
+		},
+	}
+}
+
+// quotaError 把*service.QuotaExceededError转换为429的openai.APIError，其他错误原样返回
+func quotaError(err error) error {
+	var exceeded *service.QuotaExceededError
+	if !errors.As(err, &exceeded) {
+		return err
+	}
+	limitType := "requests"
+	if errors.Is(err, service.ErrTokensExceeded) {
+		limitType = "tokens"
+	}
+	return &openai.APIError{
+		HTTPStatusCode: 429,
+		Code:           "rate_limit_exceeded",
+		Type:           limitType,
+		Message:        exceeded.Error(),
+	}
+}
+
+// setRateLimitHeaders 按OpenAI的格式返回最紧的限额，未设置限额的项不返回
+func setRateLimitHeaders(c *gin.Context, status *model.QuotaStatus) {
+	if status == nil {
+		return
+	}
+	if status.RequestsLimit > 0 {
+		c.Header("X-RateLimit-Limit-Requests", strconv.Itoa(status.RequestsLimit))
+		c.Header("X-RateLimit-Remaining-Requests", strconv.Itoa(status.RequestsRemaining))
+		c.Header("X-RateLimit-Reset-Requests", status.RequestsReset.Round(time.Second).String())
+	}
+	if status.TokensLimit > 0 {
+		c.Header("X-RateLimit-Limit-Tokens", strconv.Itoa(status.TokensLimit))
+		c.Header("X-RateLimit-Remaining-Tokens", strconv.Itoa(status.TokensRemaining))
+		c.Header("X-RateLimit-Reset-Tokens", status.TokensReset.Round(time.Second).String())
+	}
+}
+
+type QuotaHandler interface {
+	GetQuotas(*gin.Context)
+	SaveQuota(*gin.Context)
+	DeleteQuota(*gin.Context)
+}
+
+func NewQuotaHandler(service service.QuotaService, cfg *config.OpenAI, admins []string) QuotaHandler {
+	return &DefaultQuotaHandler{service, cfg, newAdminSet(admins)}
+}
+
+type DefaultQuotaHandler struct {
+	service service.QuotaService
+	cfg     *config.OpenAI
+	admins  adminSet
+}
+
+// GetQuotas 返回配置中的默认限额和用户在数据库中的覆盖配置
+func (h *DefaultQuotaHandler) GetQuotas(c *gin.Context) {
+	uid, ok := h.admins.targetUID(c)
+	if !ok {
+		return
+	}
+	overrides, err := h.service.GetOverrides(uid)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	defaults := []model.QuotaLimit{{
+		RequestsPerMinute: h.cfg.Quota.RequestsPerMinute,
+		TokensPerDay:      h.cfg.Quota.TokensPerDay,
+	}}
+	for _, item := range h.cfg.Models {
+		if item.Quota != (config.Quota{}) {
+			defaults = append(defaults, model.QuotaLimit{
+				Model:             item.Name,
+				RequestsPerMinute: item.Quota.RequestsPerMinute,
+				TokensPerDay:      item.Quota.TokensPerDay,
+			})
+		}
+	}
+	c.JSON(200, map[string]any{
+		"defaults":  defaults,
+		"overrides": overrides,
+	})
+}
+
+// SaveQuota 设置用户的限额，只有管理员可以调用
+func (h *DefaultQuotaHandler) SaveQuota(c *gin.Context) {
+	if !h.admins.requireAdmin(c) {
+		return
+	}
+	uid, _ := h.admins.targetUID(c)
+	var req model.QuotaLimit
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	if req.RequestsPerMinute < 0 || req.TokensPerDay < 0 {
+		c.String(400, "limits must not be negative")
+		return
+	}
+	err = h.service.SaveOverride(uid, &req)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
+
+// DeleteQuota 删除用户的限额，恢复使用配置中的默认值，只有管理员可以调用
+func (h *DefaultQuotaHandler) DeleteQuota(c *gin.Context) {
+	if !h.admins.requireAdmin(c) {
+		return
+	}
+	uid, _ := h.admins.targetUID(c)
+	err := h.service.DeleteOverride(uid, c.Query("model"))
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.String(200, "success")
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/constants"
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type SettingsHandler interface {
+	GetSettings(*gin.Context)
+	SaveSettings(*gin.Context)
+}
+
+func NewSettingsHandler(service service.SettingsService) SettingsHandler {
+	return &DefaultSettingsHandler{service}
+}
+
+type DefaultSettingsHandler struct {
+	service service.SettingsService
+}
+
+func (h *DefaultSettingsHandler) GetSettings(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	settings, err := h.service.GetSettings(user.ID)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, settings)
+}
+
+func (h *DefaultSettingsHandler) SaveSettings(c *gin.Context) {
+	user := c.Value(constants.UserSessionKey).(model.User)
+	// 未提供的字段保持原值
+	settings, err := h.service.GetSettings(user.ID)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	err = c.ShouldBindJSON(settings)
+	if err != nil {
+		c.String(400, err.Error())
+		return
+	}
+	err = h.service.SaveSettings(user.ID, settings)
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, settings)
+}
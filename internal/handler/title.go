@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/constants"
+	"github.com/coxlong/eureka/internal/pkg/hub"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/provider"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTitlePrompt = "Generate a short title of at most 8 words for the conversation below, in the language of the user. Reply with the title only, without quotes or trailing punctuation."
+	titleTimeout       = 30 * time.Second
+	titleMaxTokens     = 32
+	// titleMaxLength 与数据库中title字段的长度一致
+	titleMaxLength = 64
+)
+
+var errTitleDisabled = errors.New("auto title disabled")
+
+// autoTitle 为刚创建的会话生成标题，保存后通过hub通知用户的所有连接。
+// 在请求结束后的后台运行，不能使用请求的gin.Context
+func (h *DefaultChatHandler) autoTitle(req *ChatCompletionRequest, answer model.Message) (string, error) {
+	if h.title.Disabled {
+		return "", errTitleDisabled
+	}
+	uid := req.caller.uid
+	settings, err := h.settingsService.GetSettings(uid)
+	if err != nil {
+		return "", err
+	}
+	if !settings.AutoTitle {
+		return "", errTitleDisabled
+	}
+
+	modelName := req.Model
+	if h.title.Model != "" {
+		modelName = h.title.Model
+	}
+	route, ok := h.registry.Resolve(modelName)
+	if !ok {
+		return "", fmt.Errorf("title model %s does not exist", modelName)
+	}
+	var transcript strings.Builder
+	for _, message := range req.Messages {
+		if message.Role == openai.ChatMessageRoleUser {
+			fmt.Fprintf(&transcript, "%s: %s\n\n", message.Role, message.Text())
+		}
+	}
+	fmt.Fprintf(&transcript, "%s: %s", answer.Role, answer.Content)
+	titleReq := &openai.ChatCompletionRequest{
+		Model:     route.Name,
+		MaxTokens: titleMaxTokens,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: h.title.Prompt},
+			{Role: openai.ChatMessageRoleUser, Content: transcript.String()},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+	defer cancel()
+	response, upstream, err := provider.Failover(ctx, h.retry, provider.Upstreams(route), func(ctx context.Context, upstream config.Upstream) (openai.ChatCompletionResponse, error) {
+		client, upstreamReq, err := h.newClient(req.caller, &upstream, titleReq)
+		if err != nil {
+			return openai.ChatCompletionResponse{}, err
+		}
+		return client.CreateChatCompletion(ctx, upstreamReq)
+	})
+	if err != nil {
+		return "", err
+	}
+	h.recordUsage(uid, req.ID, "", route, upstream, completionUsage(route, titleReq.Messages, response))
+	if len(response.Choices) == 0 {
+		return "", errors.New("title model returned no choices")
+	}
+	title := cleanTitle(response.Choices[0].Message.Content)
+	if title == "" {
+		return "", errors.New("title model returned empty title")
+	}
+
+	// 用户在生成期间手动修改过标题时不再覆盖
+	meta, _, err := h.service.GetConversation(req.ID, uid)
+	if err != nil {
+		return "", err
+	}
+	if meta.Title != "" {
+		return meta.Title, nil
+	}
+	if err := h.service.UpdateTitle(uid, req.ID, title); err != nil {
+		return "", err
+	}
+	h.hub.Publish(uid, "", titleEvent(req.ID, title))
+	return title, nil
+}
+
+// autoTitleAsync 在后台生成标题，客户端通过hub或重新获取会话得到标题
+func (h *DefaultChatHandler) autoTitleAsync(req *ChatCompletionRequest, answer model.Message) {
+	go func() {
+		if _, err := h.autoTitle(req, answer); err != nil && !errors.Is(err, errTitleDisabled) {
+			log.Warn("generate title failed", zap.String("conversation_id", req.ID), zap.Error(err))
+		}
+	}()
+}
+
+func titleEvent(cid, title string) hub.Event {
+	return hub.Event{
+		Type: constants.EventConversationTitle,
+		Data: map[string]any{
+			"conversation_id": cid,
+			"title":           title,
+		},
+	}
+}
+
+// cleanTitle 去掉模型常带的引号、换行和结尾标点，并按字段长度截断
+func cleanTitle(title string) string {
+	title = strings.TrimSpace(title)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.Trim(title, " \t\"'`“”‘’「」《》*#")
+	title = strings.TrimRight(title, ".。!！")
+	for utf8.RuneCountInString(title) > titleMaxLength {
+		_, size := utf8.DecodeLastRuneInString(title)
+		title = title[:len(title)-size]
+	}
+	return strings.TrimSpace(title)
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+	"github.com/coxlong/eureka/internal/tool"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxToolIterations = 5
+	// eventToolSteps 服务端执行工具后发出的事件，数据为工具调用和结果消息
+	eventToolSteps = "tool"
+)
+
+// enableServerTools 校验请求启用的服务端工具，并把它们的定义加入发给上游的tools
+func (h *DefaultChatHandler) enableServerTools(req *ChatCompletionRequest) error {
+	if len(req.ServerTools) == 0 {
+		return nil
+	}
+	if req.N > 1 {
+		return &openai.APIError{
+			HTTPStatusCode: 400,
+			Type:           "invalid_request_error",
+			Message:        "server_tools does not support n > 1",
+		}
+	}
+	for _, name := range req.ServerTools {
+		t, ok := h.tools.Get(name)
+		if !ok {
+			return &openai.APIError{
+				HTTPStatusCode: 400,
+				Type:           "invalid_request_error",
+				Message:        fmt.Sprintf("unknown server tool %s", name),
+			}
+		}
+		definition := t.Definition()
+		exists := slices.ContainsFunc(req.Tools, func(item openai.Tool) bool {
+			return item.Function.Name == name
+		})
+		if !exists {
+			req.Tools = append(req.Tools, openai.Tool{
+				Type:     openai.ToolTypeFunction,
+				Function: definition,
+			})
+		}
+	}
+	return nil
+}
+
+// runsServerTools 所有工具调用都是启用的服务端工具时返回true，
+// 只要有一个客户端工具就把整轮调用交给客户端
+func (req *ChatCompletionRequest) runsServerTools(calls []model.ToolCall) bool {
+	if len(calls) == 0 {
+		return false
+	}
+	for _, call := range calls {
+		if !slices.Contains(req.ServerTools, call.Function.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// runTools 执行assistant消息中的工具调用。assistant消息和每个工具结果依次挂在
+// CurrentNodeID之下并加入下一轮请求，保存会话时与最终回答一起写入消息树
+func (h *DefaultChatHandler) runTools(ctx context.Context, req *ChatCompletionRequest, route *config.Model, assistant model.Message) []model.Message {
+	// 最终回答沿用answerID，中间的assistant消息使用新的ID
+	assistant.ID = uuid.NewString()
+	assistant.Parent = req.CurrentNodeID
+	assistant.Status = model.MessageStatusFinished
+	steps := []model.Message{assistant}
+	parent := assistant.ID
+	for _, call := range toOpenaiToolCalls(assistant.ToolCalls) {
+		content := h.tools.Call(ctx, call)
+		steps = append(steps, model.Message{
+			ID:         uuid.NewString(),
+			Parent:     parent,
+			Role:       openai.ChatMessageRoleTool,
+			Content:    content,
+			ToolCallID: call.ID,
+		})
+		parent = steps[len(steps)-1].ID
+	}
+
+	req.Messages = append(req.Messages, steps...)
+	req.CurrentNodeID = parent
+	req.ChatCompletionRequest.Messages = append(req.ChatCompletionRequest.Messages, toOpenaiMessages(steps)...)
+	if tok, err := tokenizer.ForModel(route.ModelID, route.Encoding); err == nil {
+		req.promptTokens = tok.CountMessages(req.ChatCompletionRequest.Messages)
+	} else {
+		log.Warn("load tokenizer failed", zap.Error(err))
+	}
+	return steps
+}
+
+func marshalSteps(steps []model.Message) []byte {
+	rByte, _ := json.Marshal(steps)
+	return rByte
+}
+
+func addUsage(a, b openai.Usage) openai.Usage {
+	return openai.Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+	}
+}
+
+func toOpenaiToolCalls(calls []model.ToolCall) []openai.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]openai.ToolCall, 0, len(calls))
+	for _, call := range calls {
+		result = append(result, openai.ToolCall{
+			ID:   call.ID,
+			Type: openai.ToolType(call.Type),
+			Function: openai.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		})
+	}
+	return result
+}
+
+func fromOpenaiToolCalls(calls []openai.ToolCall) []model.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]model.ToolCall, 0, len(calls))
+	for _, call := range calls {
+		result = append(result, model.ToolCall{
+			ID:   call.ID,
+			Type: string(call.Type),
+			Function: model.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		})
+	}
+	return result
+}
+
+type ToolsHandler interface {
+	GetTools(*gin.Context)
+}
+
+func NewToolsHandler(registry *tool.Registry) ToolsHandler {
+	return &DefaultToolsHandler{registry}
+}
+
+type DefaultToolsHandler struct {
+	registry *tool.Registry
+}
+
+// GetTools 返回可以通过server_tools启用的工具
+func (h *DefaultToolsHandler) GetTools(c *gin.Context) {
+	c.JSON(200, map[string]any{
+		"object": "list",
+		"data":   h.registry.Definitions(),
+	})
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUsageDays 未指定时间范围时返回最近30天的用量
+const defaultUsageDays = 30
+
+type UsageHandler interface {
+	GetUsage(*gin.Context)
+}
+
+func NewUsageHandler(service service.UsageService, admins []string) UsageHandler {
+	return &DefaultUsageHandler{service, newAdminSet(admins)}
+}
+
+type DefaultUsageHandler struct {
+	service service.UsageService
+	admins  adminSet
+}
+
+// GetUsage 按天或按月汇总用户每个模型的用量，from和to为UTC日期(2006-01-02)，包含to当天
+func (h *DefaultUsageHandler) GetUsage(c *gin.Context) {
+	uid, ok := h.admins.targetUID(c)
+	if !ok {
+		return
+	}
+	period := c.DefaultQuery("period", model.UsagePeriodDaily)
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query("to"); value != "" {
+		var err error
+		if to, err = time.Parse(time.DateOnly, value); err != nil {
+			c.String(400, "invalid to")
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -defaultUsageDays+1)
+	if value := c.Query("from"); value != "" {
+		var err error
+		if from, err = time.Parse(time.DateOnly, value); err != nil {
+			c.String(400, "invalid from")
+			return
+		}
+	}
+	stats, err := h.service.GetUsage(uid, period, from, to.AddDate(0, 0, 1))
+	if errors.Is(err, service.ErrInvalidUsagePeriod) {
+		c.String(400, err.Error())
+		return
+	}
+	if err != nil {
+		c.String(500, err.Error())
+		return
+	}
+	c.JSON(200, map[string]any{
+		"period": period,
+		"from":   from.Format(time.DateOnly),
+		"to":     to.Format(time.DateOnly),
+		"data":   stats,
+	})
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// AttachmentURLPrefix 消息中引用已上传图片的地址前缀，如attachment://<id>，
+// 发给上游前替换为data URL或临时链接
+const AttachmentURLPrefix = "attachment://"
+
+// Attachment 用户上传的图片，只有上传者可以访问
+type Attachment struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"-"`
+}
+
+func (a Attachment) MarshalJSON() ([]byte, error) {
+	type Alias Attachment
+	return json.Marshal(struct {
+		Alias
+		URL       string `json:"url"`
+		CreatedAt int64  `json:"created_at"`
+	}{
+		Alias:     (Alias)(a),
+		URL:       AttachmentURLPrefix + a.ID,
+		CreatedAt: a.CreatedAt.UnixMilli(),
+	})
+}
+
+// AttachmentID 返回attachment://地址中的ID，不是附件地址时返回false
+func AttachmentID(url string) (string, bool) {
+	if !strings.HasPrefix(url, AttachmentURLPrefix) {
+		return "", false
+	}
+	id := strings.TrimPrefix(url, AttachmentURLPrefix)
+	return id, id != ""
+}
@@ -1,49 +1,260 @@
 package model
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 )
 
+const (
+	// MessageStatusFinished 回答已完整生成
+	MessageStatusFinished = "finished"
+	// MessageStatusIncomplete 回答在生成过程中被中断
+	MessageStatusIncomplete = "incomplete"
+	// MessageStatusCancelled 回答被用户取消
+	MessageStatusCancelled = "cancelled"
+
+	// ContentPartText 多段内容中的文本
+	ContentPartText = "text"
+	// ContentPartImageURL 多段内容中的图片
+	ContentPartImageURL = "image_url"
+
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	// RoleSummary 记忆模式下保存的摘要节点，挂在它覆盖的最后一条消息之下
+	RoleSummary = "summary"
+)
+
+// ToolCall 模型发起的工具调用，格式与OpenAI一致
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ContentPart 多段内容中的一段，格式与OpenAI一致
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
 type Message struct {
-	ID        string    `json:"id"`
-	Parent    string    `json:"parent"`
-	Role      string    `json:"role"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"-"`
+	ID      string `json:"id"`
+	Parent  string `json:"parent"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// MultiContent 内容为数组时使用，此时Content为空，JSON中同样序列化为content
+	MultiContent []ContentPart `json:"-"`
+	// Name 消息发送者的名字，function消息中为函数名
+	Name string `json:"name,omitempty"`
+	// FunctionCall 旧版function calling中assistant发起的调用
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	// ToolCalls assistant消息中的工具调用
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID tool消息对应的工具调用
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Citations 回答引用的知识库分块
+	Citations []Citation `json:"citations,omitempty"`
+	// Upstream 实际生成该回答的上游
+	Upstream string `json:"upstream,omitempty"`
+	// Status 回答的状态，用户消息和旧数据为空
+	Status string `json:"status,omitempty"`
+	// PromptTokens 同一次生成的多个回答中只记在第一个回答上
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	Cost             float64   `json:"cost,omitempty"`
+	CreatedAt        time.Time `json:"-"`
 }
 
 func (m Message) MarshalJSON() ([]byte, error) {
 	type Alias Message
+	var content any = m.Content
+	if len(m.MultiContent) > 0 {
+		content = m.MultiContent
+	}
 	return json.Marshal(struct {
 		Alias
+		Content   any   `json:"content"`
 		CreatedAt int64 `json:"created_at"`
 	}{
 		Alias:     (Alias)(m),
+		Content:   content,
 		CreatedAt: m.CreatedAt.UnixMilli(),
 	})
 }
 
+// UnmarshalJSON content可以是字符串、内容数组或null(只有工具调用的assistant消息)
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type Alias Message
+	aux := struct {
+		*Alias
+		Content json.RawMessage `json:"content"`
+	}{
+		Alias: (*Alias)(m),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	content := bytes.TrimSpace(aux.Content)
+	switch {
+	case len(content) == 0 || bytes.Equal(content, []byte("null")):
+		m.Content = ""
+	case content[0] == '[':
+		m.Content = ""
+		return json.Unmarshal(content, &m.MultiContent)
+	default:
+		return json.Unmarshal(content, &m.Content)
+	}
+	return nil
+}
+
+// Text 返回消息的文本内容，多段内容时拼接其中的文本
+func (m *Message) Text() string {
+	if len(m.MultiContent) == 0 {
+		return m.Content
+	}
+	var texts []string
+	for _, part := range m.MultiContent {
+		if part.Type == ContentPartText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
 type ConversationMeta struct {
-	ID            string    `json:"id"`
-	Title         string    `json:"title"`
-	Model         string    `json:"model"`
-	MaxTokens     int       `json:"max_tokens"`
-	Temperature   float32   `json:"temperature"`
-	CurrentNodeID string    `json:"current_node_id"`
-	CreatedAt     time.Time `json:"-"`
-	UpdatedAt     time.Time `json:"-"`
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	Model         string  `json:"model"`
+	MaxTokens     int     `json:"max_tokens"`
+	Temperature   float32 `json:"temperature"`
+	CurrentNodeID string  `json:"current_node_id"`
+	// CollectionID 关联的知识库
+	CollectionID string   `json:"collection_id,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// Pinned 置顶的会话排在列表最前面
+	Pinned bool `json:"pinned"`
+	// Archived 归档的会话默认不出现在列表中
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+	// DeletedAt 移入回收站的时间，不在回收站中时为零值
+	DeletedAt time.Time `json:"-"`
 }
 
 func (c *ConversationMeta) MarshalJSON() ([]byte, error) {
 	type Alias ConversationMeta
+	var deletedAt int64
+	if !c.DeletedAt.IsZero() {
+		deletedAt = c.DeletedAt.UnixMilli()
+	}
 	return json.Marshal(struct {
 		*Alias
 		CreatedAt int64 `json:"created_at"`
 		UpdatedAt int64 `json:"updated_at"`
+		DeletedAt int64 `json:"deleted_at,omitempty"`
 	}{
 		Alias:     (*Alias)(c),
 		CreatedAt: c.CreatedAt.UnixMilli(),
 		UpdatedAt: c.UpdatedAt.UnixMilli(),
+		DeletedAt: deletedAt,
 	})
 }
+
+// ConversationFilter 会话列表的筛选条件和分页位置
+type ConversationFilter struct {
+	Model string
+	Tag   string
+	// Pinned、Archived 为nil时不限制
+	Pinned   *bool
+	Archived *bool
+	// From、To 按更新时间筛选的区间[From, To)，零值表示不限制
+	From time.Time
+	To   time.Time
+	// After 上一页最后一个会话的位置，为nil时从第一页开始
+	After *ConversationCursor
+	Limit int
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ConversationCursor 会话在列表中的位置，列表按(pinned, updated_at, id)降序排列
+type ConversationCursor struct {
+	Pinned    bool
+	UpdatedAt time.Time
+	ID        string
+}
+
+// String 编码为客户端使用的不透明字符串，时间保留数据库返回的完整精度
+func (c ConversationCursor) String() string {
+	pinned := "0"
+	if c.Pinned {
+		pinned = "1"
+	}
+	return encodeCursor(pinned, c.UpdatedAt.Format(time.RFC3339Nano), c.ID)
+}
+
+func ParseConversationCursor(value string) (*ConversationCursor, error) {
+	parts, err := decodeCursor(value, 3)
+	if err != nil || (parts[0] != "0" && parts[0] != "1") {
+		return nil, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &ConversationCursor{Pinned: parts[0] == "1", UpdatedAt: t, ID: parts[2]}, nil
+}
+
+// TrashCursor 会话在回收站列表中的位置，列表按(deleted_at, id)降序排列
+type TrashCursor struct {
+	DeletedAt time.Time
+	ID        string
+}
+
+func (c TrashCursor) String() string {
+	return encodeCursor(c.DeletedAt.Format(time.RFC3339Nano), c.ID)
+}
+
+func ParseTrashCursor(value string) (*TrashCursor, error) {
+	parts, err := decodeCursor(value, 2)
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &TrashCursor{DeletedAt: t, ID: parts[1]}, nil
+}
+
+// encodeCursor 用|连接各个字段，只有最后一个字段(会话ID)可以包含|
+func encodeCursor(parts ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, "|")))
+}
+
+func decodeCursor(value string, n int) ([]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(data), "|", n)
+	if len(parts) != n || parts[n-1] == "" {
+		return nil, ErrInvalidCursor
+	}
+	return parts, nil
+}
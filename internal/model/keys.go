@@ -0,0 +1,25 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProviderKey 用户保存的模型服务商密钥，Key字段在存储层为密文
+type ProviderKey struct {
+	Provider  string    `json:"provider"`
+	Key       string    `json:"-"`
+	Hint      string    `json:"hint"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+func (k ProviderKey) MarshalJSON() ([]byte, error) {
+	type Alias ProviderKey
+	return json.Marshal(struct {
+		Alias
+		UpdatedAt int64 `json:"updated_at"`
+	}{
+		Alias:     (Alias)(k),
+		UpdatedAt: k.UpdatedAt.UnixMilli(),
+	})
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// DocumentStatusProcessing 文档正在分块和计算向量
+	DocumentStatusProcessing = "processing"
+	DocumentStatusReady      = "ready"
+	DocumentStatusFailed     = "failed"
+)
+
+// Collection 知识库，会话关联知识库后回答时引用其中的文档
+type Collection struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"-"`
+}
+
+func (c Collection) MarshalJSON() ([]byte, error) {
+	type Alias Collection
+	return json.Marshal(struct {
+		Alias
+		CreatedAt int64 `json:"created_at"`
+	}{
+		Alias:     (Alias)(c),
+		CreatedAt: c.CreatedAt.UnixMilli(),
+	})
+}
+
+type Document struct {
+	ID           string `json:"id"`
+	CollectionID string `json:"collection_id"`
+	Name         string `json:"name"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Status       string `json:"status"`
+	// Error 处理失败的原因
+	Error     string    `json:"error,omitempty"`
+	Chunks    int       `json:"chunks"`
+	CreatedAt time.Time `json:"-"`
+}
+
+func (d Document) MarshalJSON() ([]byte, error) {
+	type Alias Document
+	return json.Marshal(struct {
+		Alias
+		CreatedAt int64 `json:"created_at"`
+	}{
+		Alias:     (Alias)(d),
+		CreatedAt: d.CreatedAt.UnixMilli(),
+	})
+}
+
+// Chunk 文档的一个分块，Index为分块在文档中的序号
+type Chunk struct {
+	ID           string
+	CollectionID string
+	DocumentID   string
+	Index        int
+	Content      string
+}
+
+// RetrievedChunk 检索到的分块及其相似度
+type RetrievedChunk struct {
+	Chunk
+	DocumentName string
+	Score        float64
+}
+
+// Citation 回答引用的分块，Index与注入prompt中的编号[n]一致
+type Citation struct {
+	Index        int     `json:"index"`
+	DocumentID   string  `json:"document_id"`
+	DocumentName string  `json:"document_name"`
+	ChunkID      string  `json:"chunk_id"`
+	Score        float64 `json:"score"`
+	Snippet      string  `json:"snippet"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// QuotaLimit 限额，值为0表示不限制
+type QuotaLimit struct {
+	// Model 为空时限制用户所有模型的总量
+	Model             string `json:"model"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	TokensPerDay      int    `json:"tokens_per_day"`
+}
+
+// QuotaStatus 本次请求后最紧的限额及剩余量，Limit为0表示不限制
+type QuotaStatus struct {
+	RequestsLimit     int
+	RequestsRemaining int
+	RequestsReset     time.Duration
+	TokensLimit       int
+	TokensRemaining   int
+	TokensReset       time.Duration
+}
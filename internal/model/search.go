@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ConversationMessage 消息及其所属的会话
+type ConversationMessage struct {
+	ConversationID string
+	Title          string
+	Message
+}
+
+// SearchResult 搜索命中的消息
+type SearchResult struct {
+	ConversationID string `json:"conversation_id"`
+	Title          string `json:"title"`
+	// MessageID 只有标题命中时为空
+	MessageID string `json:"message_id,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Snippet   string `json:"snippet"`
+	// Highlight HTML转义后的摘录，关键词用<mark>标记，只在关键词搜索时返回
+	Highlight string `json:"highlight,omitempty"`
+	// Score 语义搜索的相似度
+	Score     float64   `json:"score,omitempty"`
+	CreatedAt time.Time `json:"-"`
+}
+
+func (r SearchResult) MarshalJSON() ([]byte, error) {
+	type Alias SearchResult
+	return json.Marshal(struct {
+		Alias
+		CreatedAt int64 `json:"created_at"`
+	}{
+		Alias:     (Alias)(r),
+		CreatedAt: r.CreatedAt.UnixMilli(),
+	})
+}
@@ -0,0 +1,14 @@
+package model
+
+// Settings 用户的个人偏好
+type Settings struct {
+	// AutoTitle 是否在首轮对话后自动生成会话标题
+	AutoTitle bool `json:"auto_title"`
+}
+
+// DefaultSettings 用户未保存过设置时使用的默认值
+func DefaultSettings() Settings {
+	return Settings{
+		AutoTitle: true,
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	UsagePeriodDaily   = "daily"
+	UsagePeriodMonthly = "monthly"
+)
+
+// UsageRecord 一次上游调用的token用量，包括生成摘要、标题等后台调用
+type UsageRecord struct {
+	ConversationID   string    `json:"conversation_id,omitempty"`
+	MessageID        string    `json:"message_id,omitempty"`
+	Model            string    `json:"model"`
+	Upstream         string    `json:"upstream,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	CreatedAt        time.Time `json:"-"`
+}
+
+func (u UsageRecord) MarshalJSON() ([]byte, error) {
+	type Alias UsageRecord
+	return json.Marshal(struct {
+		Alias
+		CreatedAt int64 `json:"created_at"`
+	}{
+		Alias:     (Alias)(u),
+		CreatedAt: u.CreatedAt.UnixMilli(),
+	})
+}
+
+// UsageStat 按周期和模型汇总的用量，Period为2006-01-02或2006-01
+type UsageStat struct {
+	Period           string  `json:"period"`
+	Model            string  `json:"model"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
@@ -0,0 +1,15 @@
+package model
+
+// Vector 向量索引中的一项，Ref为所属的对象，如分块所在的文档
+type Vector struct {
+	ID     string
+	Ref    string
+	Values []float32
+}
+
+// VectorMatch 检索结果，Score为余弦相似度
+type VectorMatch struct {
+	ID    string
+	Ref   string
+	Score float64
+}
@@ -0,0 +1,37 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/coxlong/eureka/internal/pkg/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store 按key保存文件内容，key由调用方生成，可以包含"/"
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get 返回文件内容，不存在时返回ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner 可以生成临时访问链接的存储
+type Presigner interface {
+	PresignGet(key string, expires time.Duration) (string, error)
+}
+
+func New(cfg *config.Blob) (Store, error) {
+	switch cfg.Type {
+	case "", "local":
+		return NewLocalStore(cfg.Dir)
+	case "s3":
+		return NewS3Store(&cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown blob store type %s", cfg.Type)
+	}
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -16,6 +18,12 @@ type Config struct {
 	Database      Database
 	Authorization Authorization
 	OpenAI        OpenAI
+	Vault         Vault
+	Tools         Tools
+	Attachments   Attachments
+	Knowledge     Knowledge
+	Search        Search
+	Trash         Trash
 }
 type Env struct {
 	Mode         string
@@ -48,10 +56,187 @@ type Authorization struct {
 	SessionKey         string
 	GithubClient       string
 	GithubClientSecret string
+	// Admins 管理员的用户ID
+	Admins []string
 }
 
 type OpenAI struct {
-	BaseURL string
+	// Provider 上游类型：openai、anthropic、gemini、ollama，默认为openai
+	Provider string
+	BaseURL  string
+	// APIKey 组织级别的密钥，用户未配置密钥时使用
+	APIKey string
+	// KeySource、KeyName 默认上游的密钥来源和在用户密钥库中的名称，含义与Upstream相同。
+	// 以前的版本总是把请求头中的密钥转发给上游，依赖这一行为的部署需要设置KeySource为request；
+	// BaseURL为代理地址时需要设置KeyName才会使用用户密钥库中的密钥
+	KeySource string
+	KeyName   string
+	// Models 模型路由表，为空时所有模型都转发到上面的默认上游
+	Models []Model
+	Retry  Retry
+	// SummaryModel 截断策略为summarize时用于生成摘要的模型，为空时使用请求的模型
+	SummaryModel string
+	Memory       Memory
+	Title        Title
+	// Quota 每个用户所有模型合计的默认限额
+	Quota Quota
+	// EmbeddingModel 知识库等计算向量使用的模型，配置了路由表时需要在其中，默认为text-embedding-3-small
+	EmbeddingModel string
+}
+
+// Quota 限额，0表示不限制
+type Quota struct {
+	RequestsPerMinute int
+	TokensPerDay      int
+}
+
+// Title 首轮对话后自动生成会话标题
+type Title struct {
+	Disabled bool
+	// Model 生成标题使用的模型，为空时使用对话的模型
+	Model string
+	// Prompt 生成标题的system提示词，为空时使用内置的提示词
+	Prompt string
+}
+
+// Memory 记忆模式下对长对话做滚动摘要
+type Memory struct {
+	// TokenBudget 历史消息超过该token数时把较早的消息总结为摘要，默认为4000
+	TokenBudget int
+	// RecentTokens 生成摘要时保留原文的最近消息的token数，默认为TokenBudget的一半
+	RecentTokens int
+}
+
+// Retry 上游返回429、5xx或网络错误时的重试策略
+type Retry struct {
+	// MaxAttempts 每个上游的最大尝试次数，默认为1
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Upstream 上游服务配置
+type Upstream struct {
+	Provider string
+	BaseURL  string
+	// ModelID 上游实际使用的模型ID，为空时与模型名称相同
+	ModelID string
+	// KeySource 密钥来源：request、user、org，为空时依次尝试用户密钥库和组织密钥。
+	// 只有为request时才把请求头中的密钥转发给上游
+	KeySource string
+	// KeyName 该上游在用户密钥库中的密钥名称。为空时只有服务商官方地址的上游才使用
+	// 以服务商类型保存的密钥，用户的密钥不会发往代理或其他地址
+	KeyName string
+	// APIKey 该上游的组织密钥
+	APIKey string
+}
+
+// Model 客户端可见的模型，请求中的model字段按Name匹配
+type Model struct {
+	Name        string
+	Upstream    `mapstructure:",squash"`
+	MaxTokens   int
+	Temperature float32
+	// ContextWindow 模型的上下文长度，为0时不截断
+	ContextWindow int
+	// Encoding 计算token使用的BPE编码：cl100k_base、o200k_base，为空时根据模型ID推断
+	Encoding string
+	// Truncation 超出上下文时的截断策略：drop_oldest、keep_system、summarize，默认为keep_system
+	Truncation string
+	Price      Price
+	// Quota 每个用户使用该模型的默认限额
+	Quota Quota
+	// Fallbacks 主上游失败后依次尝试的备用上游
+	Fallbacks []Upstream
+}
+
+// Price 模型每百万token的价格
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+type Vault struct {
+	// Secret 用于派生加密用户密钥的密钥，为空时不启用用户密钥库，不能与Authorization.SessionKey相同。
+	// 以前的版本在Secret为空时使用SessionKey加密，这些密钥需要在设置Secret后重新保存
+	Secret string
+}
+
+// Tools 服务端执行的工具
+type Tools struct {
+	// MaxIterations 一次请求中服务端连续执行工具调用的最大轮数，默认为5
+	MaxIterations int
+	Fetch         Fetch
+}
+
+// Fetch http_fetch工具的配置
+type Fetch struct {
+	// AllowedHosts 允许访问的主机，支持"*.example.com"，为空时不启用该工具
+	AllowedHosts []string
+	// MaxBytes 返回给模型的最大字节数，默认为64KB
+	MaxBytes int
+	Timeout  time.Duration
+}
+
+// Knowledge 知识库
+type Knowledge struct {
+	// MaxBytes 上传文档的最大字节数，默认为10MB
+	MaxBytes int
+	// ChunkTokens 文档分块的token数，默认为500
+	ChunkTokens int
+	// ChunkOverlap 相邻分块重叠的token数，默认为50
+	ChunkOverlap int
+	// TopK 每次回答引用的分块数，默认为4
+	TopK int
+	// MinScore 引用分块的最低余弦相似度，默认为0.3
+	MinScore float64
+}
+
+// Search 会话搜索
+type Search struct {
+	// DisableSemantic 为true时不计算消息的向量，语义搜索不可用
+	DisableSemantic bool
+}
+
+// Trash 会话回收站
+type Trash struct {
+	// RetentionDays 会话在回收站中保留的天数，超过后永久删除，默认为30，小于0时不自动删除
+	RetentionDays int
+	// DisablePurge 为true时本实例不运行定期清理。每个实例默认每小时清理一次，
+	// 多个实例同时清理只是重复查询，部署多个副本时可以只在一个实例上开启
+	DisablePurge bool
+}
+
+// Attachments 用户上传的图片
+type Attachments struct {
+	// MaxBytes 单个图片的最大字节数，默认为20MB
+	MaxBytes int
+	// SignedURL 为true且存储支持时，发给上游的是临时链接而不是data URL。
+	// 只有OpenAI兼容的上游会下载链接中的图片
+	SignedURL bool
+	// SignedURLExpiry 临时链接的有效期，默认为1小时
+	SignedURLExpiry time.Duration
+	Store           Blob
+}
+
+// Blob 文件存储
+type Blob struct {
+	// Type 存储类型：local、s3，默认为local
+	Type string
+	// Dir local存储的根目录，默认为data/attachments
+	Dir string
+	S3  S3
+}
+
+// S3 S3兼容的对象存储，如AWS S3、MinIO
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle 使用endpoint/bucket/key形式的地址，MinIO等自建服务通常需要开启
+	PathStyle bool
 }
 
 func LoadConf(fileName string) (*Config, error) {
@@ -4,3 +4,15 @@ const (
 	UserSessionName = "eureka"
 	UserSessionKey  = "user_session_key"
 )
+
+// 推送给用户其他连接的会话更新事件
+const (
+	EventConversationMessage = "conversation.message"
+	EventConversationTitle   = "conversation.title"
+	// EventConversationDeleted 会话移入回收站
+	EventConversationDeleted = "conversation.deleted"
+	// EventConversationRestored 会话从回收站恢复
+	EventConversationRestored = "conversation.restored"
+	// EventDocumentStatus 知识库文档处理完成或失败
+	EventDocumentStatus = "document.status"
+)
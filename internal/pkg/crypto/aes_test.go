@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher("vault secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plaintext := range []string{"", "sk-test", "密钥 with unicode"} {
+		encrypted, err := c.Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := c.Decrypt(encrypted)
+		if err != nil || decrypted != plaintext {
+			t.Fatalf("got %q %v, want %q", decrypted, err, plaintext)
+		}
+	}
+
+	// 每次加密使用随机nonce
+	a, _ := c.Encrypt("sk-test")
+	b, _ := c.Encrypt("sk-test")
+	if a == b {
+		t.Fatal("ciphertexts of the same plaintext should differ")
+	}
+
+	// 同一个secret派生出相同的密钥
+	other, _ := NewCipher("vault secret")
+	if decrypted, err := other.Decrypt(a); err != nil || decrypted != "sk-test" {
+		t.Fatalf("got %q %v", decrypted, err)
+	}
+}
+
+func TestCipherTamper(t *testing.T) {
+	c, _ := NewCipher("vault secret")
+	encrypted, _ := c.Encrypt("sk-test")
+	data, _ := base64.StdEncoding.DecodeString(encrypted)
+
+	for i := range data {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 1
+		if _, err := c.Decrypt(base64.StdEncoding.EncodeToString(tampered)); err == nil {
+			t.Fatalf("flipping byte %d should fail", i)
+		}
+	}
+	if _, err := c.Decrypt(base64.StdEncoding.EncodeToString(data[:len(data)-1])); err == nil {
+		t.Fatal("truncated ciphertext should fail")
+	}
+	if _, err := c.Decrypt(base64.StdEncoding.EncodeToString(data[:5])); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Fatalf("got %v, want ErrInvalidCiphertext", err)
+	}
+	if _, err := c.Decrypt("not base64!"); err == nil {
+		t.Fatal("malformed base64 should fail")
+	}
+
+	wrong, _ := NewCipher("another secret")
+	if _, err := wrong.Decrypt(encrypted); err == nil {
+		t.Fatal("decrypting with another secret should fail")
+	}
+	if _, err := NewCipher(""); err == nil {
+		t.Fatal("empty secret should fail")
+	}
+}
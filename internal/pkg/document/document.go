@@ -0,0 +1,167 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+)
+
+const (
+	TypePDF      = "application/pdf"
+	TypeMarkdown = "text/markdown"
+	TypeText     = "text/plain"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported document type, expected pdf, markdown or text")
+	ErrNoText          = errors.New("document contains no extractable text")
+)
+
+// DetectType 根据内容和扩展名判断文档类型
+func DetectType(name string, data []byte) (string, error) {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return TypePDF, nil
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", ErrUnsupportedType
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown":
+		return TypeMarkdown, nil
+	case "", ".txt", ".text", ".log", ".csv":
+		return TypeText, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// Extract 返回文档的纯文本
+func Extract(contentType string, data []byte) (string, error) {
+	var text string
+	switch contentType {
+	case TypePDF:
+		var err error
+		text, err = extractPDF(data)
+		if err != nil {
+			return "", err
+		}
+	case TypeMarkdown, TypeText:
+		text = string(data)
+	default:
+		return "", ErrUnsupportedType
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if strings.TrimSpace(text) == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// unit 分块的最小单位，sep为与前一个单位之间的分隔符
+type unit struct {
+	text   string
+	sep    string
+	tokens int
+}
+
+// Chunk 把文本切成约size个token的分块，相邻分块重叠约overlap个token。
+// 以句子为单位组合，过长的句子按字符切分。按单位分别计数，合并后的实际token数可能略有出入
+func Chunk(tok *tokenizer.Tokenizer, text string, size, overlap int) []string {
+	var units []unit
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		sep := "\n\n"
+		for _, sentence := range splitSentences(strings.TrimSpace(paragraph)) {
+			for _, part := range splitLong(tok, sentence, size) {
+				units = append(units, unit{text: part, sep: sep, tokens: tok.Count(part)})
+				// 同一句切开的部分之间和中文句子之间不加空格
+				sep = ""
+			}
+			if last, _ := utf8.DecodeLastRuneInString(sentence); last < utf8.RuneSelf {
+				sep = " "
+			}
+		}
+	}
+
+	var chunks []string
+	var current []unit
+	// fresh 上次切分之后新加入的单位数，为0时current中只有重叠部分
+	total, fresh := 0, 0
+	flush := func() {
+		chunks = append(chunks, joinUnits(current))
+		// 保留末尾的单位作为下一块的开头
+		keep, kept := len(current), 0
+		for keep > 0 && kept+current[keep-1].tokens <= overlap {
+			keep--
+			kept += current[keep].tokens
+		}
+		current = append([]unit(nil), current[keep:]...)
+		total, fresh = kept, 0
+	}
+	for _, u := range units {
+		if fresh > 0 && total+u.tokens > size {
+			flush()
+		}
+		// 重叠部分加上新单位放不下时不保留重叠
+		if total+u.tokens > size {
+			current, total = nil, 0
+		}
+		current = append(current, u)
+		total += u.tokens
+		fresh++
+	}
+	if fresh > 0 {
+		chunks = append(chunks, joinUnits(current))
+	}
+	return chunks
+}
+
+func joinUnits(units []unit) string {
+	var b strings.Builder
+	for i, u := range units {
+		if i > 0 {
+			b.WriteString(u.sep)
+		}
+		b.WriteString(u.text)
+	}
+	return b.String()
+}
+
+// splitLong 按字符切分超过size个token的句子
+func splitLong(tok *tokenizer.Tokenizer, sentence string, size int) []string {
+	if tok.Count(sentence) <= size {
+		return []string{sentence}
+	}
+	var parts []string
+	runes := []rune(sentence)
+	for len(runes) > 0 {
+		// 每个token至少对应一个字符，从size个字符开始逐步缩短
+		n := min(size, len(runes))
+		for n > 1 && tok.Count(string(runes[:n])) > size {
+			n = n * 3 / 4
+		}
+		parts = append(parts, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return parts
+}
+
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(text)
+	for i, r := range runes {
+		if strings.ContainsRune(".!?。！？\n", r) && (i+1 == len(runes) || r > 127 || runes[i+1] == ' ' || runes[i+1] == '\n') {
+			if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+				sentences = append(sentences, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
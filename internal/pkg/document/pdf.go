@@ -0,0 +1,322 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxStreamBytes 单个解压后的流的上限，防止压缩炸弹
+const maxStreamBytes = 32 << 20
+
+var lengthPattern = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+
+// extractPDF 从PDF的内容流中提取文本。只处理未压缩和FlateDecode的流，
+// 以及单字节编码或UTF-16BE的字符串；使用CID字体且没有ToUnicode映射的文档
+// (常见于部分中文PDF)和扫描件无法提取
+func extractPDF(data []byte) (string, error) {
+	var text strings.Builder
+	for offset := 0; ; {
+		dict, content, next, ok := nextStream(data, offset)
+		if !ok {
+			break
+		}
+		offset = next
+		if bytes.Contains(dict, []byte("/Subtype/Image")) || bytes.Contains(dict, []byte("/Subtype /Image")) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/Filter")) {
+			if !bytes.Contains(dict, []byte("/FlateDecode")) {
+				continue
+			}
+			inflated, err := inflate(content)
+			if err != nil {
+				continue
+			}
+			content = inflated
+		}
+		if !bytes.Contains(content, []byte("BT")) {
+			continue
+		}
+		if page := contentText(content); strings.TrimSpace(page) != "" {
+			text.WriteString(page)
+			text.WriteString("\n\n")
+		}
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return "", ErrNoText
+	}
+	return text.String(), nil
+}
+
+// nextStream 返回offset之后的下一个流的字典和原始数据
+func nextStream(data []byte, offset int) (dict, content []byte, next int, ok bool) {
+	for {
+		i := bytes.Index(data[offset:], []byte("stream"))
+		if i < 0 {
+			return nil, nil, 0, false
+		}
+		start := offset + i
+		offset = start + len("stream")
+		// 排除endstream，stream关键字前必须是字典的结尾
+		before := bytes.TrimRight(data[:start], " \t\r\n")
+		if !bytes.HasSuffix(before, []byte(">>")) {
+			continue
+		}
+		dictEnd := len(before)
+		dictStart := matchDictStart(data, dictEnd)
+		if dictStart < 0 {
+			continue
+		}
+		dict = data[dictStart:dictEnd]
+		body := offset
+		if body < len(data) && data[body] == '\r' {
+			body++
+		}
+		if body < len(data) && data[body] == '\n' {
+			body++
+		}
+		end := -1
+		// 长度是间接引用时无法直接读取，改为查找endstream
+		if m := lengthPattern.FindSubmatch(dict); m != nil && len(m[2]) == 0 {
+			// 与剩余长度比较，过大的长度相加会溢出
+			if n, err := strconv.Atoi(string(m[1])); err == nil && n <= len(data)-body {
+				end = body + n
+			}
+		}
+		if end < 0 {
+			j := bytes.Index(data[body:], []byte("endstream"))
+			if j < 0 {
+				return nil, nil, 0, false
+			}
+			end = body + j
+		}
+		return dict, data[body:end], end, true
+	}
+}
+
+// matchDictStart 从字典结尾的">>"向前找到与之匹配的"<<"
+func matchDictStart(data []byte, end int) int {
+	depth := 0
+	for i := end - 1; i > 0; i-- {
+		switch {
+		case data[i] == '>' && data[i-1] == '>':
+			depth++
+			i--
+		case data[i] == '<' && data[i-1] == '<':
+			depth--
+			i--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func inflate(data []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// 流结尾可能不完整，保留已解压的部分
+	result, err := io.ReadAll(io.LimitReader(reader, maxStreamBytes))
+	if len(result) > 0 {
+		return result, nil
+	}
+	return nil, err
+}
+
+// contentText 解释内容流中的文本操作符
+func contentText(content []byte) string {
+	var text strings.Builder
+	var operands []string
+	var numbers []float64
+	newline := func() {
+		if text.Len() > 0 && !strings.HasSuffix(text.String(), "\n") {
+			text.WriteByte('\n')
+		}
+	}
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isPDFSpace(c):
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, n := readLiteral(content[i:])
+			operands = append(operands, s)
+			i += n
+		case c == '<' && i+1 < len(content) && content[i+1] == '<', c == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return text.String()
+			}
+			operands = append(operands, decodeHex(content[i+1:i+end]))
+			i += end + 1
+		case c == '[' || c == ']' || c == '{' || c == '}':
+			i++
+		case c == '/':
+			i++
+			for i < len(content) && !isPDFSpace(content[i]) && !isPDFDelimiter(content[i]) {
+				i++
+			}
+		default:
+			start := i
+			for i < len(content) && !isPDFSpace(content[i]) && !isPDFDelimiter(content[i]) {
+				i++
+			}
+			if i == start {
+				i++
+				continue
+			}
+			token := string(content[start:i])
+			if f, err := strconv.ParseFloat(token, 64); err == nil {
+				// TJ数组中较大的负间距通常表示单词之间的空格
+				if f < -200 && len(operands) > 0 {
+					operands = append(operands, " ")
+				}
+				numbers = append(numbers, f)
+				continue
+			}
+			switch token {
+			case "Tj", "TJ":
+				text.WriteString(strings.Join(operands, ""))
+			case "'", "\"":
+				newline()
+				text.WriteString(strings.Join(operands, ""))
+			case "T*", "ET":
+				newline()
+			case "Td", "TD":
+				if len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					newline()
+				} else if len(numbers) >= 2 && numbers[len(numbers)-2] > 0 && !strings.HasSuffix(text.String(), " ") {
+					text.WriteByte(' ')
+				}
+			case "BI":
+				// 跳过内嵌图片的数据
+				if end := bytes.Index(content[i:], []byte("EI")); end >= 0 {
+					i += end + 2
+				}
+			}
+			operands = operands[:0]
+			numbers = numbers[:0]
+		}
+	}
+	return text.String()
+}
+
+// readLiteral 读取以"("开始的字符串，返回解码后的文本和消耗的字节数
+func readLiteral(data []byte) (string, int) {
+	var raw []byte
+	depth := 0
+	i := 0
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				raw = append(raw, '\n')
+			case 'r':
+				raw = append(raw, '\r')
+			case 't':
+				raw = append(raw, '\t')
+			case 'b':
+				raw = append(raw, '\b')
+			case 'f':
+				raw = append(raw, '\f')
+			case '\r', '\n':
+				// 续行
+				if e == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					v, j := 0, 0
+					for ; j < 3 && i+j < len(data) && data[i+j] >= '0' && data[i+j] <= '7'; j++ {
+						v = v*8 + int(data[i+j]-'0')
+					}
+					raw = append(raw, byte(v))
+					i += j - 1
+				} else {
+					raw = append(raw, e)
+				}
+			}
+		case c == '(':
+			depth++
+			if depth > 1 {
+				raw = append(raw, c)
+			}
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return decodeString(raw), i + 1
+			}
+			raw = append(raw, c)
+		default:
+			raw = append(raw, c)
+		}
+	}
+	return decodeString(raw), i
+}
+
+func decodeHex(data []byte) string {
+	var digits []byte
+	for _, c := range data {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	raw := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return ""
+		}
+		raw = append(raw, byte(v))
+	}
+	return decodeString(raw)
+}
+
+// decodeString 解码UTF-16BE(带BOM)和单字节编码的字符串，无法映射的双字节字形编码返回空
+func decodeString(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	if bytes.IndexByte(raw, 0) >= 0 {
+		return ""
+	}
+	// 按Latin-1近似WinAnsiEncoding和PDFDocEncoding
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
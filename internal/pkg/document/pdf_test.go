@@ -0,0 +1,95 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const helloContent = "BT /F1 12 Tf 72 712 Td (Hello World) Tj ET"
+
+// pdfWithStream 返回只有一个流对象的PDF，dict为流字典的内容
+func pdfWithStream(dict string, content []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n1 0 obj\n<< " + dict + " >>\nstream\n")
+	b.Write(content)
+	b.WriteString("\nendstream\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func deflate(data string) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write([]byte(data))
+	w.Close()
+	return b.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	compressed := deflate(helloContent)
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"uncompressed", pdfWithStream(fmt.Sprintf("/Length %d", len(helloContent)), []byte(helloContent)), "Hello World"},
+		{"flate", pdfWithStream(fmt.Sprintf("/Length %d /Filter /FlateDecode", len(compressed)), compressed), "Hello World"},
+		// 长度是间接引用、超出文件、超出int范围或者会溢出时查找endstream
+		{"indirect length", pdfWithStream("/Length 2 0 R", []byte(helloContent)), "Hello World"},
+		{"length past end", pdfWithStream("/Length 99999", []byte(helloContent)), "Hello World"},
+		{"length overflows int", pdfWithStream("/Length 99999999999999999999", []byte(helloContent)), "Hello World"},
+		{"length overflows offset", pdfWithStream("/Length 9223372036854775807", []byte(helloContent)), "Hello World"},
+		{"escapes", pdfWithStream("", []byte(`BT (a\(b\) \101\tc) Tj ET`)), "a(b) A\tc"},
+		{"utf-16", pdfWithStream("", []byte(`BT <FEFF004F004B> Tj ET`)), "OK"},
+		{"word spacing", pdfWithStream("", []byte(`BT [(Hello) -300 (World)] TJ ET`)), "Hello World"},
+		{"lines", pdfWithStream("", []byte(`BT (one) Tj T* (two) Tj 0 -14 Td (three) Tj ET`)), "one\ntwo\nthree"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			text, err := extractPDF(c.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(text) != c.want {
+				t.Fatalf("got %q, want %q", text, c.want)
+			}
+		})
+	}
+}
+
+func TestExtractPDFNoText(t *testing.T) {
+	compressed := deflate(helloContent)
+	cases := map[string][]byte{
+		"image":          pdfWithStream("/Subtype /Image /Length 43", []byte(helloContent)),
+		"unknown filter": pdfWithStream("/Filter /DCTDecode", []byte(helloContent)),
+		"corrupt flate":  pdfWithStream("/Filter /FlateDecode", []byte("not zlib data")),
+		// 长度超出文件且没有endstream
+		"missing endstream": []byte("%PDF-1.4\n1 0 obj\n<< /Length 9223372036854775807 >>\nstream\n" + helloContent),
+		"no streams":        []byte("%PDF-1.4\n%%EOF\n"),
+		"truncated flate":   pdfWithStream("/Filter /FlateDecode", compressed[:2]),
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			if text, err := extractPDF(data); !errors.Is(err, ErrNoText) {
+				t.Fatalf("got %q %v, want ErrNoText", text, err)
+			}
+		})
+	}
+}
+
+// 截断在任意位置的文件都不能导致panic
+func TestExtractPDFTruncated(t *testing.T) {
+	compressed := deflate(strings.Repeat(helloContent+"\n", 20))
+	for _, data := range [][]byte{
+		pdfWithStream(fmt.Sprintf("/Length %d", len(helloContent)), []byte(helloContent)),
+		pdfWithStream(fmt.Sprintf("/Length %d /Filter /FlateDecode", len(compressed)), compressed),
+		pdfWithStream("/Length 9223372036854775807", []byte(`BT (a\(b) <FEFF004F> [(x) -300] TJ BI EI ET`)),
+	} {
+		for i := range data {
+			extractPDF(data[:i])
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package hub
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const bufferSize = 16
+
+// Event 推送给用户所有在线连接的事件
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// Hub 按用户分组的进程内事件广播
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[string]map[string]*Subscription
+}
+
+func New() *Hub {
+	return &Hub{
+		subs: map[string]map[string]*Subscription{},
+	}
+}
+
+type Subscription struct {
+	ID  string
+	C   <-chan Event
+	c   chan Event
+	uid string
+	hub *Hub
+}
+
+func (h *Hub) Subscribe(uid string) *Subscription {
+	c := make(chan Event, bufferSize)
+	sub := &Subscription{
+		ID:  uuid.NewString(),
+		C:   c,
+		c:   c,
+		uid: uid,
+		hub: h,
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[uid] == nil {
+		h.subs[uid] = map[string]*Subscription{}
+	}
+	h.subs[uid][sub.ID] = sub
+	return sub
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	delete(s.hub.subs[s.uid], s.ID)
+	if len(s.hub.subs[s.uid]) == 0 {
+		delete(s.hub.subs, s.uid)
+	}
+}
+
+// Publish 把事件发送给用户除exclude之外的所有订阅，订阅方处理不过来时丢弃事件
+func (h *Hub) Publish(uid, exclude string, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for id, sub := range h.subs[uid] {
+		if id == exclude {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	EncodingCL100K = "cl100k_base"
+	EncodingO200K  = "o200k_base"
+
+	// 参考OpenAI的计算方式，每条消息和回复前缀各有固定的额外开销
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+	// 工具定义在上游被转换为system提示词的一部分，按JSON计数再加固定开销近似
+	tokensPerTool = 8
+
+	// 不读取图片尺寸，low按固定开销计算，其余按1024x1024(4个512的分块)估算
+	imageBaseTokens = 85
+	imageTileTokens = 170
+	imageTiles      = 4
+)
+
+var (
+	mu        sync.Mutex
+	encodings = map[string]*tiktoken.Tiktoken{}
+)
+
+func init() {
+	// 使用随程序打包的BPE文件，避免运行时下载
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// Tokenizer 基于BPE的token计数器
+type Tokenizer struct {
+	encoding *tiktoken.Tiktoken
+}
+
+// ForModel 返回模型对应的Tokenizer，encoding为空时根据模型名称推断，
+// 无法推断的模型(如非OpenAI模型)按cl100k_base近似计算
+func ForModel(model, encoding string) (*Tokenizer, error) {
+	if encoding == "" {
+		encoding = EncodingCL100K
+		if name, ok := encodingForModel(model); ok {
+			encoding = name
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if tke, ok := encodings[encoding]; ok {
+		return &Tokenizer{tke}, nil
+	}
+	tke, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	encodings[encoding] = tke
+	return &Tokenizer{tke}, nil
+}
+
+func encodingForModel(model string) (string, bool) {
+	if name, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		return name, true
+	}
+	for prefix, name := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+		if len(model) > len(prefix) && model[:len(prefix)] == prefix {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (t *Tokenizer) Count(text string) int {
+	return len(t.encoding.EncodeOrdinary(text))
+}
+
+// CountMessage 返回单条消息占用的token数
+func (t *Tokenizer) CountMessage(message openai.ChatCompletionMessage) int {
+	n := tokensPerMessage + t.Count(message.Role) + t.Count(message.Content)
+	for _, part := range message.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			n += t.Count(part.Text)
+		} else if part.ImageURL != nil {
+			n += imageTokens(part.ImageURL.Detail)
+		}
+	}
+	if message.Name != "" {
+		n += t.Count(message.Name) + 1
+	}
+	if message.FunctionCall != nil {
+		n += t.Count(message.FunctionCall.Name) + t.Count(message.FunctionCall.Arguments)
+	}
+	for _, call := range message.ToolCalls {
+		n += t.Count(call.Function.Name) + t.Count(call.Function.Arguments)
+	}
+	return n
+}
+
+func imageTokens(detail openai.ImageURLDetail) int {
+	if detail == openai.ImageURLDetailLow {
+		return imageBaseTokens
+	}
+	return imageBaseTokens + imageTileTokens*imageTiles
+}
+
+// CountMessages 返回整个prompt占用的token数
+func (t *Tokenizer) CountMessages(messages []openai.ChatCompletionMessage) int {
+	n := tokensPerReply
+	for _, message := range messages {
+		n += t.CountMessage(message)
+	}
+	return n
+}
+
+// CountTools 返回tools和functions中的函数定义占用的token数
+func (t *Tokenizer) CountTools(tools []openai.Tool, functions []openai.FunctionDefinition) int {
+	n := 0
+	for _, item := range tools {
+		n += t.countFunction(item.Function)
+	}
+	for _, item := range functions {
+		n += t.countFunction(item)
+	}
+	return n
+}
+
+func (t *Tokenizer) countFunction(function openai.FunctionDefinition) int {
+	n := tokensPerTool + t.Count(function.Name) + t.Count(function.Description)
+	if function.Parameters != nil {
+		if data, err := json.Marshal(function.Parameters); err == nil {
+			n += t.Count(string(data))
+		}
+	}
+	return n
+}
@@ -0,0 +1,133 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 与OpenAI cookbook中How to count tokens with tiktoken的结果一致
+func TestCount(t *testing.T) {
+	cases := []struct {
+		text   string
+		cl100k int
+		o200k  int
+	}{
+		{"", 0, 0},
+		{"hello world", 2, 2},
+		{"tiktoken is great!", 6, 6},
+		{"2 + 2 = 4", 7, 7},
+		{"お誕生日おめでとう", 9, 8},
+		{"antidisestablishmentarianism", 6, 6},
+	}
+	cl100k, err := ForModel("", EncodingCL100K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o200k, err := ForModel("", EncodingO200K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if n := cl100k.Count(c.text); n != c.cl100k {
+			t.Errorf("cl100k_base Count(%q) = %d, want %d", c.text, n, c.cl100k)
+		}
+		if n := o200k.Count(c.text); n != c.o200k {
+			t.Errorf("o200k_base Count(%q) = %d, want %d", c.text, n, c.o200k)
+		}
+	}
+}
+
+func TestForModel(t *testing.T) {
+	cases := []struct {
+		model    string
+		encoding string
+	}{
+		{"gpt-4", EncodingCL100K},
+		{"gpt-3.5-turbo", EncodingCL100K},
+		{"text-embedding-3-small", EncodingCL100K},
+		{"gpt-4o", EncodingO200K},
+		{"gpt-4o-mini", EncodingO200K},
+		// 无法推断的模型按cl100k_base计算
+		{"claude-3-5-sonnet", EncodingCL100K},
+	}
+	for _, c := range cases {
+		tok, err := ForModel(c.model, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := ForModel("", c.encoding)
+		if tok.encoding != want.encoding {
+			t.Errorf("ForModel(%s) does not use %s", c.model, c.encoding)
+		}
+	}
+	// 显式指定的编码优先
+	tok, _ := ForModel("gpt-4", EncodingO200K)
+	if want, _ := ForModel("", EncodingO200K); tok.encoding != want.encoding {
+		t.Error("explicit encoding is ignored")
+	}
+	if _, err := ForModel("gpt-4", "unknown_base"); err == nil {
+		t.Error("unknown encoding should fail")
+	}
+}
+
+// 与OpenAI cookbook中num_tokens_from_messages的示例一致
+func TestCountMessages(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: "system", Content: "You are a helpful, pattern-following assistant that translates corporate jargon into plain English."},
+		{Role: "system", Name: "example_user", Content: "New synergies will help drive top-line growth."},
+		{Role: "system", Name: "example_assistant", Content: "Things working well together will increase revenue."},
+		{Role: "system", Name: "example_user", Content: "Let's circle back when we have more bandwidth to touch base on opportunities for increased leverage."},
+		{Role: "system", Name: "example_assistant", Content: "Let's talk later when we're less busy about how to do better."},
+		{Role: "user", Content: "This late pivot means we don't have time to boil the ocean for the client deliverable."},
+	}
+	for model, want := range map[string]int{"gpt-4": 129, "gpt-4o": 124} {
+		tok, _ := ForModel(model, "")
+		if n := tok.CountMessages(messages); n != want {
+			t.Errorf("%s: CountMessages = %d, want %d", model, n, want)
+		}
+	}
+
+	tok, _ := ForModel("gpt-4", "")
+	if n := tok.CountMessages(nil); n != tokensPerReply {
+		t.Errorf("empty prompt = %d, want %d", n, tokensPerReply)
+	}
+	// 多段内容中的文本逐段计数，图片按detail估算
+	multi := openai.ChatCompletionMessage{Role: "user", MultiContent: []openai.ChatMessagePart{
+		{Type: openai.ChatMessagePartTypeText, Text: "hello world"},
+		{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "data:", Detail: openai.ImageURLDetailLow}},
+		{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "data:"}},
+	}}
+	if n, want := tok.CountMessage(multi), tokensPerMessage+1+2+imageBaseTokens+imageBaseTokens+imageTileTokens*imageTiles; n != want {
+		t.Errorf("multi content = %d, want %d", n, want)
+	}
+	call := openai.ChatCompletionMessage{Role: "assistant", ToolCalls: []openai.ToolCall{{
+		Type:     openai.ToolTypeFunction,
+		Function: openai.FunctionCall{Name: "calculator", Arguments: `{"expression":"1+2"}`},
+	}}}
+	if n, want := tok.CountMessage(call), tokensPerMessage+1+tok.Count("calculator")+tok.Count(`{"expression":"1+2"}`); n != want {
+		t.Errorf("tool call = %d, want %d", n, want)
+	}
+}
+
+func TestCountTools(t *testing.T) {
+	tok, _ := ForModel("gpt-4", "")
+	parameters := json.RawMessage(`{"type":"object","properties":{"expression":{"type":"string"}}}`)
+	tools := []openai.Tool{{Type: openai.ToolTypeFunction, Function: openai.FunctionDefinition{
+		Name:        "calculator",
+		Description: "evaluate an expression",
+		Parameters:  parameters,
+	}}}
+	want := tokensPerTool + tok.Count("calculator") + tok.Count("evaluate an expression") + tok.Count(string(parameters))
+	if n := tok.CountTools(tools, nil); n != want {
+		t.Errorf("CountTools = %d, want %d", n, want)
+	}
+	functions := []openai.FunctionDefinition{{Name: "calculator"}}
+	if n := tok.CountTools(tools, functions); n != want+tokensPerTool+tok.Count("calculator") {
+		t.Errorf("CountTools with functions = %d", n)
+	}
+	if n := tok.CountTools(nil, nil); n != 0 {
+		t.Errorf("no tools = %d, want 0", n)
+	}
+}
@@ -0,0 +1,372 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	anthropicBaseURL          = "https://api.anthropic.com"
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+)
+
+// AnthropicProvider Anthropic Messages API
+type AnthropicProvider struct {
+	baseURL string
+	apiKey  string
+}
+
+func NewAnthropicProvider(baseURL, apiKey string) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = anthropicBaseURL
+	}
+	return &AnthropicProvider{strings.TrimRight(baseURL, "/"), apiKey}
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+	// ID Name Input 用于tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// ToolUseID Content 用于tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicRequest struct {
+	Model         string               `json:"model"`
+	System        string               `json:"system,omitempty"`
+	Messages      []anthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   float32              `json:"temperature,omitempty"`
+	TopP          float32              `json:"top_p,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+func (p *AnthropicProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := p.post(ctx, req, false)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	var result anthropicResponse
+	if err := decodeJSON(resp, &result); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	var content strings.Builder
+	var toolCalls []openai.ToolCall
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			call := newToolCall(block.Name, block.Input)
+			call.ID = block.ID
+			toolCalls = append(toolCalls, call)
+		}
+	}
+	return openai.ChatCompletionResponse{
+		ID:      result.ID,
+		Object:  "chat.completion",
+		Created: now(),
+		Model:   result.Model,
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Message: openai.ChatCompletionMessage{
+					Role:      openai.ChatMessageRoleAssistant,
+					Content:   content.String(),
+					ToolCalls: toolCalls,
+				},
+				FinishReason: anthropicFinishReason(result.StopReason),
+			},
+		},
+		Usage: openai.Usage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+			TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+func (p *AnthropicProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	resp, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	return &anthropicStream{
+		response: resp,
+		reader:   newSSEReader(resp.Body),
+		model:    req.Model,
+		created:  now(),
+		tools:    map[int]int{},
+	}, nil
+}
+
+func (p *AnthropicProvider) post(ctx context.Context, req openai.ChatCompletionRequest, stream bool) (*http.Response, error) {
+	if err := checkUnsupported(TypeAnthropic, req, false); err != nil {
+		return nil, err
+	}
+	system, messages := splitSystem(req.Messages)
+	body := anthropicRequest{
+		Model:         req.Model,
+		System:        system,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+		Stream:        stream,
+	}
+	if body.MaxTokens == 0 {
+		body.MaxTokens = anthropicDefaultMaxTokens
+	}
+	if err := anthropicTools(&body, req); err != nil {
+		return nil, err
+	}
+	for _, item := range messages {
+		role := openai.ChatMessageRoleUser
+		if item.Role == openai.ChatMessageRoleAssistant {
+			role = openai.ChatMessageRoleAssistant
+		}
+		blocks, err := anthropicBlocks(item)
+		if err != nil {
+			return nil, err
+		}
+		// Messages API要求user和assistant交替出现，连续的同角色消息需要合并
+		if n := len(body.Messages); n > 0 && body.Messages[n-1].Role == role {
+			body.Messages[n-1].Content = append(body.Messages[n-1].Content, blocks...)
+			continue
+		}
+		body.Messages = append(body.Messages, anthropicMessage{
+			Role:    role,
+			Content: blocks,
+		})
+	}
+	return postJSON(ctx, p.baseURL+"/v1/messages", map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}, body)
+}
+
+// anthropicBlocks 转换单条消息的内容。tool消息转换为user消息中的tool_result，
+// assistant消息的tool_calls转换为文本之后的tool_use
+func anthropicBlocks(item openai.ChatCompletionMessage) ([]anthropicBlock, error) {
+	if item.Role == openai.ChatMessageRoleTool {
+		return []anthropicBlock{{
+			Type:      "tool_result",
+			ToolUseID: item.ToolCallID,
+			Content:   MessageText(item),
+		}}, nil
+	}
+	var blocks []anthropicBlock
+	for _, image := range messageImages(item) {
+		blocks = append(blocks, anthropicBlock{
+			Type:   "image",
+			Source: &anthropicImageSource{Type: "base64", MediaType: image.MediaType, Data: image.Data},
+		})
+	}
+	if text := MessageText(item); text != "" || (len(blocks) == 0 && len(item.ToolCalls) == 0) {
+		blocks = append(blocks, anthropicBlock{Type: "text", Text: text})
+	}
+	for _, call := range item.ToolCalls {
+		input, err := toolArguments(call)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, anthropicBlock{
+			Type:  "tool_use",
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: input,
+		})
+	}
+	return blocks, nil
+}
+
+// anthropicTools 转换tools和tool_choice，required对应any，指定函数对应tool
+func anthropicTools(body *anthropicRequest, req openai.ChatCompletionRequest) error {
+	mode, name, err := parseToolChoice(req.ToolChoice)
+	if err != nil {
+		return err
+	}
+	if len(req.Tools) == 0 {
+		if mode == toolChoiceRequired || mode == toolChoiceFunction {
+			return invalidRequest("tool_choice requires tools")
+		}
+		return nil
+	}
+	for _, tool := range req.Tools {
+		schema, err := toolParameters(tool.Function)
+		if err != nil {
+			return err
+		}
+		body.Tools = append(body.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	switch mode {
+	case toolChoiceNone:
+		body.ToolChoice = &anthropicToolChoice{Type: "none"}
+	case toolChoiceRequired:
+		body.ToolChoice = &anthropicToolChoice{Type: "any"}
+	case toolChoiceFunction:
+		body.ToolChoice = &anthropicToolChoice{Type: "tool", Name: name}
+	}
+	return nil
+}
+
+func anthropicFinishReason(reason string) openai.FinishReason {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return openai.FinishReasonStop
+	case "max_tokens":
+		return openai.FinishReasonLength
+	case "tool_use":
+		return openai.FinishReasonToolCalls
+	default:
+		return openai.FinishReasonNull
+	}
+}
+
+type anthropicStream struct {
+	response *http.Response
+	reader   *sseReader
+	id       string
+	model    string
+	created  int64
+	finished bool
+	// tools 内容块的index到工具调用index的映射
+	tools map[int]int
+}
+
+func (s *anthropicStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	for !s.finished {
+		event, err := s.reader.Next()
+		if err == io.EOF {
+			// 连接在message_stop之前断开，回答不完整
+			return openai.ChatCompletionStreamResponse{}, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return openai.ChatCompletionStreamResponse{}, err
+		}
+		var data struct {
+			Message      anthropicResponse `json:"message"`
+			Index        int               `json:"index"`
+			ContentBlock anthropicBlock    `json:"content_block"`
+			Delta        struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				PartialJSON string `json:"partial_json"`
+				StopReason  string `json:"stop_reason"`
+			} `json:"delta"`
+			Error *openai.APIError `json:"error"`
+		}
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return openai.ChatCompletionStreamResponse{}, err
+		}
+		switch event.Event {
+		case "message_start":
+			s.id = data.Message.ID
+			if data.Message.Model != "" {
+				s.model = data.Message.Model
+			}
+			return newChunk(s.id, s.model, s.created, 0, openai.ChatCompletionStreamChoiceDelta{
+				Role: openai.ChatMessageRoleAssistant,
+			}, ""), nil
+		case "content_block_start":
+			if data.ContentBlock.Type != "tool_use" {
+				continue
+			}
+			index := len(s.tools)
+			s.tools[data.Index] = index
+			return newChunk(s.id, s.model, s.created, 0, openai.ChatCompletionStreamChoiceDelta{
+				ToolCalls: []openai.ToolCall{{
+					Index:    &index,
+					ID:       data.ContentBlock.ID,
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: data.ContentBlock.Name},
+				}},
+			}, ""), nil
+		case "content_block_delta":
+			switch data.Delta.Type {
+			case "text_delta":
+				return newChunk(s.id, s.model, s.created, 0, openai.ChatCompletionStreamChoiceDelta{
+					Content: data.Delta.Text,
+				}, ""), nil
+			case "input_json_delta":
+				index, ok := s.tools[data.Index]
+				if !ok {
+					continue
+				}
+				return newChunk(s.id, s.model, s.created, 0, openai.ChatCompletionStreamChoiceDelta{
+					ToolCalls: []openai.ToolCall{{
+						Index:    &index,
+						Function: openai.FunctionCall{Arguments: data.Delta.PartialJSON},
+					}},
+				}, ""), nil
+			}
+		case "message_delta":
+			return newChunk(s.id, s.model, s.created, 0, openai.ChatCompletionStreamChoiceDelta{},
+				anthropicFinishReason(data.Delta.StopReason)), nil
+		case "message_stop":
+			s.finished = true
+		case "error":
+			if data.Error != nil {
+				data.Error.HTTPStatusCode = http.StatusBadGateway
+				return openai.ChatCompletionStreamResponse{}, data.Error
+			}
+		}
+	}
+	return openai.ChatCompletionStreamResponse{}, io.EOF
+}
+
+func (s *anthropicStream) Close() {
+	s.response.Body.Close()
+}
@@ -0,0 +1,256 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestAnthropicRequest(t *testing.T) {
+	url, rec := newTestServer(t, 200, "application/json", `{"id":"msg_1","model":"test-model","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+	req := toolConversation()
+	req.ToolChoice = "required"
+	if _, err := NewAnthropicProvider(url, "sk-test").CreateChatCompletion(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.path != "/v1/messages" {
+		t.Fatalf("got path %s", rec.path)
+	}
+	if rec.header.Get("x-api-key") != "sk-test" || rec.header.Get("anthropic-version") != anthropicVersion {
+		t.Fatalf("got headers %v", rec.header)
+	}
+	var body anthropicRequest
+	rec.decode(t, &body)
+	if body.System != "be brief" || body.MaxTokens != 100 || body.Stream {
+		t.Fatalf("got system %q max_tokens %d stream %v", body.System, body.MaxTokens, body.Stream)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Name != "calculator" || body.Tools[0].Description != "evaluate an expression" {
+		t.Fatalf("got tools %+v", body.Tools)
+	}
+	assertJSON(t, body.Tools[0].InputSchema, `{"type":"object","properties":{"expression":{"type":"string"}}}`)
+	if body.ToolChoice == nil || body.ToolChoice.Type != "any" {
+		t.Fatalf("got tool_choice %+v", body.ToolChoice)
+	}
+
+	// 两个tool消息和之后的user消息合并为一条user消息，tool_result在前
+	if len(body.Messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(body.Messages))
+	}
+	assistant := body.Messages[1]
+	if assistant.Role != "assistant" || len(assistant.Content) != 2 {
+		t.Fatalf("got assistant message %+v", assistant)
+	}
+	for i, id := range []string{"call_1", "call_2"} {
+		block := assistant.Content[i]
+		if block.Type != "tool_use" || block.ID != id || block.Name != "calculator" {
+			t.Fatalf("got block %+v", block)
+		}
+	}
+	assertJSON(t, assistant.Content[0].Input, `{"expression":"1+2"}`)
+	results := body.Messages[2]
+	if results.Role != "user" || len(results.Content) != 3 {
+		t.Fatalf("got user message %+v", results)
+	}
+	if block := results.Content[0]; block.Type != "tool_result" || block.ToolUseID != "call_1" || block.Content != "3" {
+		t.Fatalf("got block %+v", block)
+	}
+	if block := results.Content[1]; block.Type != "tool_result" || block.ToolUseID != "call_2" || block.Content != `{"result":12}` {
+		t.Fatalf("got block %+v", block)
+	}
+	if block := results.Content[2]; block.Type != "text" || block.Text != "thanks" {
+		t.Fatalf("got block %+v", block)
+	}
+}
+
+func TestAnthropicToolChoice(t *testing.T) {
+	cases := []struct {
+		choice any
+		want   *anthropicToolChoice
+	}{
+		{nil, nil},
+		{"auto", nil},
+		{"none", &anthropicToolChoice{Type: "none"}},
+		{openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: "calculator"}}, &anthropicToolChoice{Type: "tool", Name: "calculator"}},
+		{map[string]any{"type": "function", "function": map[string]any{"name": "calculator"}}, &anthropicToolChoice{Type: "tool", Name: "calculator"}},
+	}
+	for _, c := range cases {
+		var body anthropicRequest
+		req := toolConversation()
+		req.ToolChoice = c.choice
+		if err := anthropicTools(&body, req); err != nil {
+			t.Fatalf("tool_choice %v: %v", c.choice, err)
+		}
+		if (body.ToolChoice == nil) != (c.want == nil) || (c.want != nil && *body.ToolChoice != *c.want) {
+			t.Fatalf("tool_choice %v: got %+v, want %+v", c.choice, body.ToolChoice, c.want)
+		}
+	}
+}
+
+func TestAnthropicUnsupported(t *testing.T) {
+	cases := map[string]func(*openai.ChatCompletionRequest){
+		"n": func(req *openai.ChatCompletionRequest) { req.N = 2 },
+		"functions": func(req *openai.ChatCompletionRequest) {
+			req.Functions = []openai.FunctionDefinition{{Name: "calculator"}}
+		},
+		"tool_choice without tools": func(req *openai.ChatCompletionRequest) {
+			req.Tools = nil
+			req.ToolChoice = "required"
+		},
+		"invalid tool_choice": func(req *openai.ChatCompletionRequest) { req.ToolChoice = "always" },
+		"invalid arguments": func(req *openai.ChatCompletionRequest) {
+			req.Messages[2].ToolCalls[0].Function.Arguments = "{"
+		},
+	}
+	for name, modify := range cases {
+		t.Run(name, func(t *testing.T) {
+			url, rec := newTestServer(t, 200, "application/json", "{}")
+			req := toolConversation()
+			modify(&req)
+			_, err := NewAnthropicProvider(url, "sk-test").CreateChatCompletion(context.Background(), req)
+			assertAPIError(t, err, http.StatusBadRequest, "invalid_request_error", "")
+			if rec.requests != 0 {
+				t.Fatal("request was sent to upstream")
+			}
+		})
+	}
+}
+
+func TestAnthropicResponse(t *testing.T) {
+	url, _ := newTestServer(t, 200, "application/json", `{
+		"id": "msg_1",
+		"model": "claude-test",
+		"content": [
+			{"type": "text", "text": "Let me calculate."},
+			{"type": "tool_use", "id": "toolu_1", "name": "calculator", "input": {"expression": "1+2"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 10, "output_tokens": 5}
+	}`)
+	resp, err := NewAnthropicProvider(url, "sk-test").CreateChatCompletion(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "msg_1" || resp.Model != "claude-test" || resp.Usage.TotalTokens != 15 {
+		t.Fatalf("got response %+v", resp)
+	}
+	choice := resp.Choices[0]
+	if choice.Message.Content != "Let me calculate." || choice.FinishReason != openai.FinishReasonToolCalls {
+		t.Fatalf("got choice %+v", choice)
+	}
+	if len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("got tool calls %+v", choice.Message.ToolCalls)
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.ID != "toolu_1" || call.Type != openai.ToolTypeFunction || call.Function.Name != "calculator" || call.Index != nil {
+		t.Fatalf("got tool call %+v", call)
+	}
+	assertJSON(t, []byte(call.Function.Arguments), `{"expression":"1+2"}`)
+}
+
+const anthropicEvents = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","model":"claude-test","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"calculate."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"calculator","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"expression\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":" \"1+2\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}
+
+`
+
+func TestAnthropicStream(t *testing.T) {
+	url, rec := newTestServer(t, 200, "text/event-stream", anthropicEvents+"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	stream, err := NewAnthropicProvider(url, "sk-test").CreateChatCompletionStream(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readStream(t, stream)
+	if err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+	var body anthropicRequest
+	rec.decode(t, &body)
+	if !body.Stream {
+		t.Fatal("stream is not set")
+	}
+	if chunks[0].ID != "msg_1" || chunks[0].Model != "claude-test" || chunks[0].Choices[0].Delta.Role != openai.ChatMessageRoleAssistant {
+		t.Fatalf("got first chunk %+v", chunks[0])
+	}
+	message := mergeChunks(chunks)
+	if message.content != "Let me calculate." || message.finishReason != openai.FinishReasonToolCalls {
+		t.Fatalf("got message %+v", message)
+	}
+	if len(message.toolCalls) != 1 || message.toolCalls[0].ID != "toolu_1" || message.toolCalls[0].Function.Name != "calculator" {
+		t.Fatalf("got tool calls %+v", message.toolCalls)
+	}
+	assertJSON(t, []byte(message.toolCalls[0].Function.Arguments), `{"expression":"1+2"}`)
+}
+
+func TestAnthropicStreamDisconnect(t *testing.T) {
+	// 没有message_stop
+	url, _ := newTestServer(t, 200, "text/event-stream", anthropicEvents)
+	stream, err := NewAnthropicProvider(url, "sk-test").CreateChatCompletionStream(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readStream(t, stream)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if mergeChunks(chunks).content != "Let me calculate." {
+		t.Fatal("chunks before the disconnect are lost")
+	}
+	if !Retryable(err) {
+		t.Fatal("early disconnect should be retryable")
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	url, _ := newTestServer(t, 200, "text/event-stream", `event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`)
+	stream, err := NewAnthropicProvider(url, "sk-test").CreateChatCompletionStream(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readStream(t, stream)
+	assertAPIError(t, err, http.StatusBadGateway, "overloaded_error", "Overloaded")
+}
+
+func TestAnthropicHTTPError(t *testing.T) {
+	url, _ := newTestServer(t, 529, "application/json", `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	_, err := NewAnthropicProvider(url, "sk-test").CreateChatCompletionStream(context.Background(), toolConversation())
+	assertAPIError(t, err, 529, "overloaded_error", "Overloaded")
+	if !Retryable(err) {
+		t.Fatal("529 should be retryable")
+	}
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// EmbeddingRequest 与OpenAI的/embeddings请求一致。go-openai中的模型是枚举，
+// 无法表示新模型和其他服务商的模型，这里使用字符串
+type EmbeddingRequest struct {
+	Input          []string `json:"input"`
+	Model          string   `json:"model"`
+	User           string   `json:"user,omitempty"`
+	EncodingFormat string   `json:"encoding_format,omitempty"`
+	Dimensions     int      `json:"dimensions,omitempty"`
+}
+
+type Embedding struct {
+	Object    string    `json:"object"`
+	Embedding []float32 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+type EmbeddingResponse struct {
+	Object string       `json:"object"`
+	Data   []Embedding  `json:"data"`
+	Model  string       `json:"model"`
+	Usage  openai.Usage `json:"usage"`
+}
+
+// Embedder 支持计算向量的上游
+type Embedder interface {
+	CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error)
+}
+
+// CreateEmbeddings 调用上游计算向量，上游不支持时返回400
+func CreateEmbeddings(ctx context.Context, p Provider, req EmbeddingRequest) (EmbeddingResponse, error) {
+	embedder, ok := p.(Embedder)
+	if !ok {
+		return EmbeddingResponse{}, &openai.APIError{
+			HTTPStatusCode: 400,
+			Type:           "invalid_request_error",
+			Message:        fmt.Sprintf("The model `%s` does not support embeddings", req.Model),
+		}
+	}
+	return embedder.CreateEmbeddings(ctx, req)
+}
+
+func (p *OpenAIProvider) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	// 向量统一按float返回，base64由调用方按需编码
+	req.EncodingFormat = ""
+	resp, err := postJSON(ctx, p.baseURL+"/embeddings", map[string]string{
+		"Authorization": "Bearer " + p.apiKey,
+	}, req)
+	if err != nil {
+		return EmbeddingResponse{}, err
+	}
+	var result EmbeddingResponse
+	err = decodeJSON(resp, &result)
+	return result, err
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+func (p *OllamaProvider) CreateEmbeddings(ctx context.Context, req EmbeddingRequest) (EmbeddingResponse, error) {
+	resp, err := postJSON(ctx, p.baseURL+"/api/embed", nil, ollamaEmbedRequest{
+		Model: req.Model,
+		Input: req.Input,
+	})
+	if err != nil {
+		return EmbeddingResponse{}, err
+	}
+	var result ollamaEmbedResponse
+	if err := decodeJSON(resp, &result); err != nil {
+		return EmbeddingResponse{}, err
+	}
+	response := EmbeddingResponse{
+		Object: "list",
+		Model:  result.Model,
+		Usage: openai.Usage{
+			PromptTokens: result.PromptEvalCount,
+			TotalTokens:  result.PromptEvalCount,
+		},
+	}
+	for i, embedding := range result.Embeddings {
+		response.Data = append(response.Data, Embedding{
+			Object:    "embedding",
+			Embedding: embedding,
+			Index:     i,
+		})
+	}
+	return response, nil
+}
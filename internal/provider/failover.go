@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 8 * time.Second
+)
+
+// RetryPolicy 单个上游的重试策略，退避时间按指数增长并加入随机抖动
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func NewRetryPolicy(cfg *config.Retry) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultMaxBackoff
+	}
+	return policy
+}
+
+// Backoff 返回第attempt次重试前的等待时间(full jitter)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// Retryable 判断错误是否值得重试：429、5xx以及网络错误
+func Retryable(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return retryableStatus(reqErr.HTTPStatusCode)
+	}
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func retryableStatus(code int) bool {
+	return code == 429 || code >= 500
+}
+
+// UpstreamName 用于记录实际提供服务的上游
+func UpstreamName(upstream config.Upstream) string {
+	name := upstream.Provider + ":" + upstream.ModelID
+	if u, err := url.Parse(upstream.BaseURL); err == nil && u.Host != "" {
+		name += "@" + u.Host
+	}
+	return name
+}
+
+// Failover 依次在各个上游上调用fn，每个上游按策略重试；
+// 只有可重试的错误才会切换到下一个上游，返回最终提供服务的上游
+func Failover[T any](
+	ctx context.Context,
+	policy RetryPolicy,
+	upstreams []config.Upstream,
+	fn func(ctx context.Context, upstream config.Upstream) (T, error),
+) (T, config.Upstream, error) {
+	var result T
+	var err error
+	for _, upstream := range upstreams {
+		for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+			if attempt > 0 {
+				timer := time.NewTimer(policy.Backoff(attempt))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return result, upstream, ctx.Err()
+				case <-timer.C:
+				}
+			}
+			result, err = fn(ctx, upstream)
+			if err == nil {
+				return result, upstream, nil
+			}
+			if !Retryable(err) || ctx.Err() != nil {
+				return result, upstream, err
+			}
+		}
+	}
+	return result, config.Upstream{}, err
+}
+
+// OpenStream 创建流并读取第一个数据块，使得上游在写出第一个token之前的错误也能触发重试
+func OpenStream(ctx context.Context, p Provider, req openai.ChatCompletionRequest) (Stream, error) {
+	stream, err := p.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	first, err := stream.Recv()
+	if err != nil && !errors.Is(err, io.EOF) {
+		stream.Close()
+		return nil, err
+	}
+	return &peekedStream{Stream: stream, first: first, firstErr: err, peeked: true}, nil
+}
+
+type peekedStream struct {
+	Stream
+	first    openai.ChatCompletionStreamResponse
+	firstErr error
+	peeked   bool
+}
+
+func (s *peekedStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if s.peeked {
+		s.peeked = false
+		return s.first, s.firstErr
+	}
+	return s.Stream.Recv()
+}
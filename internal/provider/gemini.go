@@ -0,0 +1,386 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+const geminiBaseURL = "https://generativelanguage.googleapis.com"
+
+// GeminiProvider Google Gemini generateContent API
+type GeminiProvider struct {
+	baseURL string
+	apiKey  string
+}
+
+func NewGeminiProvider(baseURL, apiKey string) *GeminiProvider {
+	if baseURL == "" {
+		baseURL = geminiBaseURL
+	}
+	return &GeminiProvider{strings.TrimRight(baseURL, "/"), apiKey}
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// ParametersJSONSchema 接受完整的JSON Schema，parameters只支持OpenAPI子集
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode                 string   `json:"mode"`
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     float32  `json:"temperature,omitempty"`
+	TopP            float32  `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	CandidateCount  int      `json:"candidateCount,omitempty"`
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent        `json:"contents"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+	Tools             []geminiTool           `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig      `json:"toolConfig,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Index        int           `json:"index"`
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func (p *GeminiProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := p.post(ctx, req, "generateContent")
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	var result geminiResponse
+	if err := decodeJSON(resp, &result); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	response := openai.ChatCompletionResponse{
+		ID:      "chatcmpl-" + uuid.NewString(),
+		Object:  "chat.completion",
+		Created: now(),
+		Model:   req.Model,
+		Usage: openai.Usage{
+			PromptTokens:     result.UsageMetadata.PromptTokenCount,
+			CompletionTokens: result.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      result.UsageMetadata.TotalTokenCount,
+		},
+	}
+	for _, candidate := range result.Candidates {
+		toolCalls := geminiToolCalls(candidate.Content)
+		finishReason := geminiFinishReason(candidate.FinishReason)
+		if len(toolCalls) > 0 && finishReason == openai.FinishReasonStop {
+			finishReason = openai.FinishReasonToolCalls
+		}
+		response.Choices = append(response.Choices, openai.ChatCompletionChoice{
+			Index: candidate.Index,
+			Message: openai.ChatCompletionMessage{
+				Role:      openai.ChatMessageRoleAssistant,
+				Content:   geminiText(candidate.Content),
+				ToolCalls: toolCalls,
+			},
+			FinishReason: finishReason,
+		})
+	}
+	return response, nil
+}
+
+func (p *GeminiProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	resp, err := p.post(ctx, req, "streamGenerateContent")
+	if err != nil {
+		return nil, err
+	}
+	return &geminiStream{
+		response:   resp,
+		reader:     newSSEReader(resp.Body),
+		id:         "chatcmpl-" + uuid.NewString(),
+		model:      req.Model,
+		created:    now(),
+		candidates: max(req.N, 1),
+		finished:   map[int]bool{},
+		toolCalls:  map[int]int{},
+	}, nil
+}
+
+func (p *GeminiProvider) post(ctx context.Context, req openai.ChatCompletionRequest, method string) (*http.Response, error) {
+	if err := checkUnsupported(TypeGemini, req, true); err != nil {
+		return nil, err
+	}
+	system, messages := splitSystem(req.Messages)
+	body := geminiRequest{
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     req.Temperature,
+			TopP:            req.TopP,
+			MaxOutputTokens: req.MaxTokens,
+			StopSequences:   req.Stop,
+			CandidateCount:  req.N,
+		},
+	}
+	if system != "" {
+		body.SystemInstruction = &geminiContent{
+			Parts: []geminiPart{{Text: system}},
+		}
+	}
+	if err := geminiTools(&body, req); err != nil {
+		return nil, err
+	}
+	names := toolCallNames(messages)
+	for i, item := range messages {
+		role := "user"
+		if item.Role == openai.ChatMessageRoleAssistant {
+			role = "model"
+		}
+		parts, err := geminiParts(item, names)
+		if err != nil {
+			return nil, err
+		}
+		// 同一轮的多个工具结果需要放在一条content中，与functionCall的数量对应
+		if i > 0 && item.Role == openai.ChatMessageRoleTool && messages[i-1].Role == openai.ChatMessageRoleTool {
+			last := &body.Contents[len(body.Contents)-1]
+			last.Parts = append(last.Parts, parts...)
+			continue
+		}
+		body.Contents = append(body.Contents, geminiContent{
+			Role:  role,
+			Parts: parts,
+		})
+	}
+	endpoint := p.baseURL + "/v1beta/models/" + url.PathEscape(req.Model) + ":" + method
+	if method == "streamGenerateContent" {
+		endpoint += "?alt=sse"
+	}
+	return postJSON(ctx, endpoint, map[string]string{
+		"x-goog-api-key": p.apiKey,
+	}, body)
+}
+
+// geminiParts 转换单条消息的内容。Gemini按函数名关联调用和结果，
+// tool消息通过tool_call_id找到对应的函数名，结果不是JSON对象时放在content字段中
+func geminiParts(item openai.ChatCompletionMessage, names map[string]string) ([]geminiPart, error) {
+	if item.Role == openai.ChatMessageRoleTool {
+		name, ok := names[item.ToolCallID]
+		if !ok {
+			return nil, invalidRequest("tool message %s does not match any tool call", item.ToolCallID)
+		}
+		text := MessageText(item)
+		response := json.RawMessage(text)
+		var object map[string]json.RawMessage
+		if json.Unmarshal(response, &object) != nil || object == nil {
+			response, _ = json.Marshal(map[string]string{"content": text})
+		}
+		return []geminiPart{{FunctionResponse: &geminiFunctionResponse{Name: name, Response: response}}}, nil
+	}
+	var parts []geminiPart
+	for _, image := range messageImages(item) {
+		parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: image.MediaType, Data: image.Data}})
+	}
+	if text := MessageText(item); text != "" || (len(parts) == 0 && len(item.ToolCalls) == 0) {
+		parts = append(parts, geminiPart{Text: text})
+	}
+	for _, call := range item.ToolCalls {
+		args, err := toolArguments(call)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: call.Function.Name, Args: args}})
+	}
+	return parts, nil
+}
+
+// geminiTools 转换tools和tool_choice，required对应ANY，指定函数对应只允许该函数的ANY
+func geminiTools(body *geminiRequest, req openai.ChatCompletionRequest) error {
+	mode, name, err := parseToolChoice(req.ToolChoice)
+	if err != nil {
+		return err
+	}
+	if len(req.Tools) == 0 {
+		if mode == toolChoiceRequired || mode == toolChoiceFunction {
+			return invalidRequest("tool_choice requires tools")
+		}
+		return nil
+	}
+	tool := geminiTool{}
+	for _, item := range req.Tools {
+		schema, err := toolParameters(item.Function)
+		if err != nil {
+			return err
+		}
+		tool.FunctionDeclarations = append(tool.FunctionDeclarations, geminiFunctionDeclaration{
+			Name:                 item.Function.Name,
+			Description:          item.Function.Description,
+			ParametersJSONSchema: schema,
+		})
+	}
+	body.Tools = []geminiTool{tool}
+	body.ToolConfig = &geminiToolConfig{}
+	config := &body.ToolConfig.FunctionCallingConfig
+	switch mode {
+	case toolChoiceNone:
+		config.Mode = "NONE"
+	case toolChoiceAuto:
+		config.Mode = "AUTO"
+	case toolChoiceRequired:
+		config.Mode = "ANY"
+	case toolChoiceFunction:
+		config.Mode = "ANY"
+		config.AllowedFunctionNames = []string{name}
+	}
+	return nil
+}
+
+// geminiToolCalls 返回内容中的functionCall，Gemini不返回调用ID，这里生成
+func geminiToolCalls(content geminiContent) []openai.ToolCall {
+	var calls []openai.ToolCall
+	for _, part := range content.Parts {
+		if part.FunctionCall != nil {
+			calls = append(calls, newToolCall(part.FunctionCall.Name, part.FunctionCall.Args))
+		}
+	}
+	return calls
+}
+
+func geminiText(content geminiContent) string {
+	var text strings.Builder
+	for _, part := range content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+func geminiFinishReason(reason string) openai.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "STOP":
+		return openai.FinishReasonStop
+	case "MAX_TOKENS":
+		return openai.FinishReasonLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return openai.FinishReasonContentFilter
+	default:
+		return openai.FinishReasonNull
+	}
+}
+
+type geminiStream struct {
+	response *http.Response
+	reader   *sseReader
+	id       string
+	model    string
+	created  int64
+	// candidates 请求的回答数，finished 已经返回finishReason的回答。
+	// Gemini的流没有结束标志，所有回答都有finishReason才认为是正常结束
+	candidates int
+	finished   map[int]bool
+	// toolCalls 每个回答已经返回的工具调用数
+	toolCalls map[int]int
+}
+
+func (s *geminiStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	for {
+		event, err := s.reader.Next()
+		if err == io.EOF && len(s.finished) < s.candidates {
+			return openai.ChatCompletionStreamResponse{}, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return openai.ChatCompletionStreamResponse{}, err
+		}
+		var data geminiResponse
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return openai.ChatCompletionStreamResponse{}, err
+		}
+		if len(data.Candidates) == 0 {
+			continue
+		}
+		chunk := openai.ChatCompletionStreamResponse{
+			ID:      s.id,
+			Object:  "chat.completion.chunk",
+			Created: s.created,
+			Model:   s.model,
+		}
+		for _, candidate := range data.Candidates {
+			if candidate.FinishReason != "" {
+				s.finished[candidate.Index] = true
+			}
+			// functionCall不会分片，每个调用完整地出现在一个事件中
+			toolCalls := geminiToolCalls(candidate.Content)
+			for i := range toolCalls {
+				index := s.toolCalls[candidate.Index]
+				toolCalls[i].Index = &index
+				s.toolCalls[candidate.Index]++
+			}
+			finishReason := geminiFinishReason(candidate.FinishReason)
+			if finishReason == openai.FinishReasonStop && s.toolCalls[candidate.Index] > 0 {
+				finishReason = openai.FinishReasonToolCalls
+			}
+			chunk.Choices = append(chunk.Choices, openai.ChatCompletionStreamChoice{
+				Index: candidate.Index,
+				Delta: openai.ChatCompletionStreamChoiceDelta{
+					Content:   geminiText(candidate.Content),
+					ToolCalls: toolCalls,
+				},
+				FinishReason: finishReason,
+			})
+		}
+		return chunk, nil
+	}
+}
+
+func (s *geminiStream) Close() {
+	s.response.Body.Close()
+}
@@ -0,0 +1,230 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestGeminiRequest(t *testing.T) {
+	url, rec := newTestServer(t, 200, "application/json", `{"candidates":[{"index":0,"content":{"role":"model","parts":[{"text":"ok"}]},"finishReason":"STOP"}]}`)
+	req := toolConversation()
+	req.N = 2
+	req.ToolChoice = openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: "calculator"}}
+	if _, err := NewGeminiProvider(url, "key-test").CreateChatCompletion(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.path != "/v1beta/models/test-model:generateContent" {
+		t.Fatalf("got path %s", rec.path)
+	}
+	if rec.header.Get("x-goog-api-key") != "key-test" {
+		t.Fatalf("got headers %v", rec.header)
+	}
+	var body geminiRequest
+	rec.decode(t, &body)
+	if body.SystemInstruction == nil || geminiText(*body.SystemInstruction) != "be brief" {
+		t.Fatalf("got system instruction %+v", body.SystemInstruction)
+	}
+	if body.GenerationConfig.CandidateCount != 2 || body.GenerationConfig.MaxOutputTokens != 100 {
+		t.Fatalf("got generation config %+v", body.GenerationConfig)
+	}
+	if len(body.Tools) != 1 || len(body.Tools[0].FunctionDeclarations) != 1 {
+		t.Fatalf("got tools %+v", body.Tools)
+	}
+	declaration := body.Tools[0].FunctionDeclarations[0]
+	if declaration.Name != "calculator" || declaration.Description != "evaluate an expression" {
+		t.Fatalf("got declaration %+v", declaration)
+	}
+	assertJSON(t, declaration.ParametersJSONSchema, `{"type":"object","properties":{"expression":{"type":"string"}}}`)
+	config := body.ToolConfig.FunctionCallingConfig
+	if config.Mode != "ANY" || len(config.AllowedFunctionNames) != 1 || config.AllowedFunctionNames[0] != "calculator" {
+		t.Fatalf("got tool config %+v", config)
+	}
+
+	// 两个tool消息合并为一条content
+	if len(body.Contents) != 4 {
+		t.Fatalf("got %d contents, want 4", len(body.Contents))
+	}
+	model := body.Contents[1]
+	if model.Role != "model" || len(model.Parts) != 2 {
+		t.Fatalf("got model content %+v", model)
+	}
+	for _, part := range model.Parts {
+		if part.FunctionCall == nil || part.FunctionCall.Name != "calculator" || part.Text != "" {
+			t.Fatalf("got part %+v", part)
+		}
+	}
+	assertJSON(t, model.Parts[1].FunctionCall.Args, `{"expression":"3*4"}`)
+	results := body.Contents[2]
+	if results.Role != "user" || len(results.Parts) != 2 {
+		t.Fatalf("got result content %+v", results)
+	}
+	for _, part := range results.Parts {
+		if part.FunctionResponse == nil || part.FunctionResponse.Name != "calculator" {
+			t.Fatalf("got part %+v", part)
+		}
+	}
+	// 不是JSON对象的结果放在content字段中
+	assertJSON(t, results.Parts[0].FunctionResponse.Response, `{"content":"3"}`)
+	assertJSON(t, results.Parts[1].FunctionResponse.Response, `{"result":12}`)
+	if body.Contents[3].Role != "user" || geminiText(body.Contents[3]) != "thanks" {
+		t.Fatalf("got last content %+v", body.Contents[3])
+	}
+}
+
+func TestGeminiToolChoice(t *testing.T) {
+	cases := []struct {
+		choice any
+		mode   string
+	}{
+		{nil, "AUTO"},
+		{"auto", "AUTO"},
+		{"none", "NONE"},
+		{"required", "ANY"},
+	}
+	for _, c := range cases {
+		var body geminiRequest
+		req := toolConversation()
+		req.ToolChoice = c.choice
+		if err := geminiTools(&body, req); err != nil {
+			t.Fatalf("tool_choice %v: %v", c.choice, err)
+		}
+		config := body.ToolConfig.FunctionCallingConfig
+		if config.Mode != c.mode || len(config.AllowedFunctionNames) != 0 {
+			t.Fatalf("tool_choice %v: got %+v, want %s", c.choice, config, c.mode)
+		}
+	}
+}
+
+func TestGeminiUnsupported(t *testing.T) {
+	cases := map[string]func(*openai.ChatCompletionRequest){
+		"functions": func(req *openai.ChatCompletionRequest) {
+			req.Functions = []openai.FunctionDefinition{{Name: "calculator"}}
+		},
+		"unknown tool call": func(req *openai.ChatCompletionRequest) {
+			req.Messages[3].ToolCallID = "call_unknown"
+		},
+		"tool_choice without tools": func(req *openai.ChatCompletionRequest) {
+			req.Tools = nil
+			req.ToolChoice = "required"
+		},
+	}
+	for name, modify := range cases {
+		t.Run(name, func(t *testing.T) {
+			url, rec := newTestServer(t, 200, "application/json", "{}")
+			req := toolConversation()
+			modify(&req)
+			_, err := NewGeminiProvider(url, "key-test").CreateChatCompletion(context.Background(), req)
+			assertAPIError(t, err, http.StatusBadRequest, "invalid_request_error", "")
+			if rec.requests != 0 {
+				t.Fatal("request was sent to upstream")
+			}
+		})
+	}
+}
+
+func TestGeminiResponse(t *testing.T) {
+	url, _ := newTestServer(t, 200, "application/json", `{
+		"candidates": [
+			{"index": 0, "content": {"role": "model", "parts": [{"text": "Let me calculate."}, {"functionCall": {"name": "calculator", "args": {"expression": "1+2"}}}]}, "finishReason": "STOP"},
+			{"index": 1, "content": {"role": "model", "parts": [{"text": "3"}]}, "finishReason": "MAX_TOKENS"}
+		],
+		"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 5, "totalTokenCount": 15}
+	}`)
+	resp, err := NewGeminiProvider(url, "key-test").CreateChatCompletion(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 5 || resp.Usage.TotalTokens != 15 || len(resp.Choices) != 2 {
+		t.Fatalf("got response %+v", resp)
+	}
+	first := resp.Choices[0]
+	if first.Message.Content != "Let me calculate." || first.FinishReason != openai.FinishReasonToolCalls || len(first.Message.ToolCalls) != 1 {
+		t.Fatalf("got choice %+v", first)
+	}
+	call := first.Message.ToolCalls[0]
+	if call.ID == "" || call.Type != openai.ToolTypeFunction || call.Function.Name != "calculator" || call.Index != nil {
+		t.Fatalf("got tool call %+v", call)
+	}
+	assertJSON(t, []byte(call.Function.Arguments), `{"expression":"1+2"}`)
+	if second := resp.Choices[1]; second.Index != 1 || second.Message.Content != "3" || second.FinishReason != openai.FinishReasonLength {
+		t.Fatalf("got choice %+v", second)
+	}
+}
+
+const geminiEvents = `data: {"candidates":[{"index":0,"content":{"role":"model","parts":[{"text":"Let me "}]}}]}
+
+data: {"candidates":[{"index":0,"content":{"role":"model","parts":[{"text":"calculate."}]}}]}
+
+`
+
+func TestGeminiStream(t *testing.T) {
+	url, rec := newTestServer(t, 200, "text/event-stream", geminiEvents+
+		`data: {"candidates":[{"index":0,"content":{"role":"model","parts":[{"functionCall":{"name":"calculator","args":{"expression":"1+2"}}},{"functionCall":{"name":"calculator","args":{"expression":"3*4"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10}}
+
+`)
+	stream, err := NewGeminiProvider(url, "key-test").CreateChatCompletionStream(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readStream(t, stream)
+	if err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+	if rec.path != "/v1beta/models/test-model:streamGenerateContent?alt=sse" {
+		t.Fatalf("got path %s", rec.path)
+	}
+	message := mergeChunks(chunks)
+	if message.content != "Let me calculate." || message.finishReason != openai.FinishReasonToolCalls {
+		t.Fatalf("got message %+v", message)
+	}
+	if len(message.toolCalls) != 2 || message.toolCalls[0].ID == message.toolCalls[1].ID {
+		t.Fatalf("got tool calls %+v", message.toolCalls)
+	}
+	assertJSON(t, []byte(message.toolCalls[1].Function.Arguments), `{"expression":"3*4"}`)
+}
+
+func TestGeminiStreamDisconnect(t *testing.T) {
+	cases := map[string]struct {
+		n      int
+		events string
+	}{
+		// 没有finishReason
+		"unfinished": {1, geminiEvents},
+		// 请求两个回答，只有一个结束
+		"candidates": {2, geminiEvents + `data: {"candidates":[{"index":0,"content":{"parts":[{"text":""}]},"finishReason":"STOP"}]}
+
+`},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			url, _ := newTestServer(t, 200, "text/event-stream", c.events)
+			req := toolConversation()
+			req.N = c.n
+			stream, err := NewGeminiProvider(url, "key-test").CreateChatCompletionStream(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunks, err := readStream(t, stream)
+			if err != io.ErrUnexpectedEOF {
+				t.Fatalf("got %v, want io.ErrUnexpectedEOF", err)
+			}
+			if mergeChunks(chunks).content != "Let me calculate." {
+				t.Fatal("chunks before the disconnect are lost")
+			}
+		})
+	}
+}
+
+func TestGeminiHTTPError(t *testing.T) {
+	url, _ := newTestServer(t, 400, "application/json", `{"error":{"code":400,"message":"Invalid JSON payload","status":"INVALID_ARGUMENT"}}`)
+	_, err := NewGeminiProvider(url, "key-test").CreateChatCompletionStream(context.Background(), toolConversation())
+	assertAPIError(t, err, 400, "INVALID_ARGUMENT", "Invalid JSON payload")
+	if Retryable(err) {
+		t.Fatal("400 should not be retryable")
+	}
+}
@@ -0,0 +1,196 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var httpClient = &http.Client{}
+
+// postJSON 发送JSON请求，上游返回错误状态码时转换为*openai.APIError
+func postJSON(ctx context.Context, url string, headers map[string]string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// decodeError 兼容{"error":"..."}和{"error":{"type":"...","message":"..."}}两种错误格式
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	apiErr := &openai.APIError{
+		HTTPStatusCode: resp.StatusCode,
+		Message:        strings.TrimSpace(string(body)),
+	}
+	var wrapped struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err == nil && len(wrapped.Error) > 0 {
+		var message string
+		var detail struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(wrapped.Error, &message); err == nil {
+			apiErr.Message = message
+		} else if err := json.Unmarshal(wrapped.Error, &detail); err == nil && detail.Message != "" {
+			apiErr.Message = detail.Message
+			apiErr.Type = detail.Type
+			if apiErr.Type == "" {
+				apiErr.Type = detail.Status
+			}
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+func decodeJSON(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type sseEvent struct {
+	Event string
+	Data  []byte
+}
+
+// sseReader 逐个读取server-sent events
+type sseReader struct {
+	reader *bufio.Reader
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	return &sseReader{bufio.NewReader(r)}
+}
+
+func (r *sseReader) Next() (sseEvent, error) {
+	var event sseEvent
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(line) == 0:
+			if event.Event != "" || event.Data != nil {
+				return event, nil
+			}
+		case bytes.HasPrefix(line, []byte("event:")):
+			event.Event = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			data := bytes.TrimPrefix(line[len("data:"):], []byte(" "))
+			if event.Data != nil {
+				event.Data = append(event.Data, '\n')
+			}
+			event.Data = append(event.Data, data...)
+		}
+		if err != nil {
+			if err == io.EOF && event.Data != nil {
+				return event, nil
+			}
+			return event, err
+		}
+	}
+}
+
+// splitSystem 将system消息合并后与其余消息分开
+func splitSystem(messages []openai.ChatCompletionMessage) (string, []openai.ChatCompletionMessage) {
+	var system []string
+	var rest []openai.ChatCompletionMessage
+	for _, item := range messages {
+		if item.Role == openai.ChatMessageRoleSystem {
+			system = append(system, MessageText(item))
+			continue
+		}
+		rest = append(rest, item)
+	}
+	return strings.Join(system, "\n\n"), rest
+}
+
+// MessageText 返回消息中的文本，多段内容时拼接其中的文本
+func MessageText(item openai.ChatCompletionMessage) string {
+	if len(item.MultiContent) == 0 {
+		return item.Content
+	}
+	var texts []string
+	for _, part := range item.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// inlineImage data URL中的图片，Data为base64编码的内容
+type inlineImage struct {
+	MediaType string
+	Data      string
+}
+
+// messageImages 返回消息中data URL形式的图片。非OpenAI的上游不会下载链接，其他地址的图片被忽略
+func messageImages(item openai.ChatCompletionMessage) []inlineImage {
+	var images []inlineImage
+	for _, part := range item.MultiContent {
+		if part.ImageURL == nil {
+			continue
+		}
+		rest, ok := strings.CutPrefix(part.ImageURL.URL, "data:")
+		if !ok {
+			continue
+		}
+		header, data, ok := strings.Cut(rest, ",")
+		mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+		if !ok || !isBase64 {
+			continue
+		}
+		images = append(images, inlineImage{MediaType: mediaType, Data: data})
+	}
+	return images
+}
+
+func newChunk(id, model string, created int64, index int, delta openai.ChatCompletionStreamChoiceDelta, finishReason openai.FinishReason) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Created: created,
+		Model:   model,
+		Choices: []openai.ChatCompletionStreamChoice{
+			{
+				Index:        index,
+				Delta:        delta,
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
+
+func now() int64 {
+	return time.Now().Unix()
+}
@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// recorder 记录测试上游收到的请求
+type recorder struct {
+	requests int
+	path     string
+	header   http.Header
+	body     []byte
+}
+
+func (r *recorder) decode(t *testing.T, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.body, v); err != nil {
+		t.Fatalf("decode request body %s: %v", r.body, err)
+	}
+}
+
+// newTestServer 启动返回固定响应的上游，body为空时只返回状态码
+func newTestServer(t *testing.T, status int, contentType, body string) (string, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.requests++
+		rec.path = r.URL.RequestURI()
+		rec.header = r.Header.Clone()
+		rec.body, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, rec
+}
+
+// readStream 读取全部chunk，返回结束时的错误
+func readStream(t *testing.T, stream Stream) ([]openai.ChatCompletionStreamResponse, error) {
+	t.Helper()
+	defer stream.Close()
+	var chunks []openai.ChatCompletionStreamResponse
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			return chunks, err
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+// streamedMessage 按index合并chunk中第一个回答的内容、工具调用和结束原因
+type streamedMessage struct {
+	content      string
+	toolCalls    []openai.ToolCall
+	finishReason openai.FinishReason
+}
+
+func mergeChunks(chunks []openai.ChatCompletionStreamResponse) streamedMessage {
+	var message streamedMessage
+	for _, chunk := range chunks {
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			message.content += choice.Delta.Content
+			for _, delta := range choice.Delta.ToolCalls {
+				index := *delta.Index
+				for len(message.toolCalls) <= index {
+					message.toolCalls = append(message.toolCalls, openai.ToolCall{})
+				}
+				call := &message.toolCalls[index]
+				if delta.ID != "" {
+					call.ID = delta.ID
+				}
+				call.Function.Name += delta.Function.Name
+				call.Function.Arguments += delta.Function.Arguments
+			}
+			if choice.FinishReason != "" {
+				message.finishReason = choice.FinishReason
+			}
+		}
+	}
+	return message
+}
+
+func assertAPIError(t *testing.T, err error, status int, typ, message string) {
+	t.Helper()
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want *openai.APIError", err)
+	}
+	if apiErr.HTTPStatusCode != status || apiErr.Type != typ || (message != "" && apiErr.Message != message) {
+		t.Fatalf("got error %d %q %q, want %d %q %q", apiErr.HTTPStatusCode, apiErr.Type, apiErr.Message, status, typ, message)
+	}
+}
+
+func assertJSON(t *testing.T, got json.RawMessage, want string) {
+	t.Helper()
+	var a, b any
+	if err := json.Unmarshal(got, &a); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &b); err != nil {
+		t.Fatalf("invalid JSON %s: %v", want, err)
+	}
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	if string(x) != string(y) {
+		t.Fatalf("got JSON %s, want %s", got, want)
+	}
+}
+
+// toolConversation 包含一轮工具调用的对话，各个上游的转换测试共用
+func toolConversation() openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Model:     "test-model",
+		MaxTokens: 100,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "be brief"},
+			{Role: openai.ChatMessageRoleUser, Content: "what is 1+2 and 3*4?"},
+			{
+				Role: openai.ChatMessageRoleAssistant,
+				ToolCalls: []openai.ToolCall{
+					{ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "calculator", Arguments: `{"expression":"1+2"}`}},
+					{ID: "call_2", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "calculator", Arguments: `{"expression":"3*4"}`}},
+				},
+			},
+			{Role: openai.ChatMessageRoleTool, ToolCallID: "call_1", Content: "3"},
+			{Role: openai.ChatMessageRoleTool, ToolCallID: "call_2", Content: `{"result":12}`},
+			{Role: openai.ChatMessageRoleUser, Content: "thanks"},
+		},
+		Tools: []openai.Tool{{
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionDefinition{
+				Name:        "calculator",
+				Description: "evaluate an expression",
+				Parameters:  json.RawMessage(`{"type":"object","properties":{"expression":{"type":"string"}}}`),
+			},
+		}},
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		body    string
+		typ     string
+		message string
+	}{
+		{"string", 404, `{"error":"model not found"}`, "", "model not found"},
+		{"object", 429, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, "rate_limit_error", "slow down"},
+		{"status", 400, `{"error":{"code":400,"message":"bad schema","status":"INVALID_ARGUMENT"}}`, "INVALID_ARGUMENT", "bad schema"},
+		{"plain", 502, "bad gateway", "", "bad gateway"},
+		{"empty", 503, "", "", "Service Unavailable"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			url, _ := newTestServer(t, c.status, "application/json", c.body)
+			_, err := postJSON(context.Background(), url, nil, map[string]string{})
+			assertAPIError(t, err, c.status, c.typ, c.message)
+		})
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	reader := newSSEReader(strings.NewReader("event: a\ndata: 1\ndata: 2\n\n: comment\ndata: 3\r\n\r\ndata: 4"))
+	want := []sseEvent{{"a", []byte("1\n2")}, {"", []byte("3")}, {"", []byte("4")}}
+	for _, w := range want {
+		event, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Event != w.Event || string(event.Data) != string(w.Data) {
+			t.Fatalf("got event %q %q, want %q %q", event.Event, event.Data, w.Event, w.Data)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,281 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+const ollamaBaseURL = "http://localhost:11434"
+
+// OllamaProvider 本地Ollama的/api/chat接口
+type OllamaProvider struct {
+	baseURL string
+}
+
+func NewOllamaProvider(baseURL string) *OllamaProvider {
+	if baseURL == "" {
+		baseURL = ollamaBaseURL
+	}
+	return &OllamaProvider{strings.TrimRight(baseURL, "/")}
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images base64编码的图片，用于多模态模型
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	// ToolName tool消息对应的函数名
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options,omitempty"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (p *OllamaProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := p.post(ctx, req, false)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	var result ollamaResponse
+	if err := decodeJSON(resp, &result); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	toolCalls := ollamaToolCalls(result.Message)
+	finishReason := ollamaFinishReason(result.DoneReason)
+	if len(toolCalls) > 0 {
+		finishReason = openai.FinishReasonToolCalls
+	}
+	return openai.ChatCompletionResponse{
+		ID:      "chatcmpl-" + uuid.NewString(),
+		Object:  "chat.completion",
+		Created: now(),
+		Model:   result.Model,
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Message: openai.ChatCompletionMessage{
+					Role:      openai.ChatMessageRoleAssistant,
+					Content:   result.Message.Content,
+					ToolCalls: toolCalls,
+				},
+				FinishReason: finishReason,
+			},
+		},
+		Usage: openai.Usage{
+			PromptTokens:     result.PromptEvalCount,
+			CompletionTokens: result.EvalCount,
+			TotalTokens:      result.PromptEvalCount + result.EvalCount,
+		},
+	}, nil
+}
+
+func (p *OllamaProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	resp, err := p.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	return &ollamaStream{
+		response: resp,
+		reader:   bufio.NewReader(resp.Body),
+		id:       "chatcmpl-" + uuid.NewString(),
+		model:    req.Model,
+		created:  now(),
+	}, nil
+}
+
+func (p *OllamaProvider) post(ctx context.Context, req openai.ChatCompletionRequest, stream bool) (*http.Response, error) {
+	if err := checkUnsupported(TypeOllama, req, false); err != nil {
+		return nil, err
+	}
+	body := ollamaRequest{
+		Model:   req.Model,
+		Stream:  stream,
+		Options: map[string]any{},
+	}
+	if err := ollamaTools(&body, req); err != nil {
+		return nil, err
+	}
+	names := toolCallNames(req.Messages)
+	for _, item := range req.Messages {
+		message := ollamaMessage{
+			Role:    item.Role,
+			Content: MessageText(item),
+		}
+		for _, image := range messageImages(item) {
+			message.Images = append(message.Images, image.Data)
+		}
+		for _, call := range item.ToolCalls {
+			arguments, err := toolArguments(call)
+			if err != nil {
+				return nil, err
+			}
+			var toolCall ollamaToolCall
+			toolCall.Function.Name = call.Function.Name
+			toolCall.Function.Arguments = arguments
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		}
+		if item.Role == openai.ChatMessageRoleTool {
+			message.ToolName = names[item.ToolCallID]
+		}
+		body.Messages = append(body.Messages, message)
+	}
+	if req.Temperature != 0 {
+		body.Options["temperature"] = req.Temperature
+	}
+	if req.TopP != 0 {
+		body.Options["top_p"] = req.TopP
+	}
+	if req.MaxTokens != 0 {
+		body.Options["num_predict"] = req.MaxTokens
+	}
+	if len(req.Stop) > 0 {
+		body.Options["stop"] = req.Stop
+	}
+	if req.Seed != nil {
+		body.Options["seed"] = *req.Seed
+	}
+	return postJSON(ctx, p.baseURL+"/api/chat", nil, body)
+}
+
+// ollamaTools 转换tools。Ollama不支持tool_choice，none时不发送tools，
+// 要求必须调用工具时返回400
+func ollamaTools(body *ollamaRequest, req openai.ChatCompletionRequest) error {
+	mode, _, err := parseToolChoice(req.ToolChoice)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case toolChoiceNone:
+		return nil
+	case toolChoiceRequired, toolChoiceFunction:
+		return invalidRequest("tool_choice %s is not supported by %s models", mode, TypeOllama)
+	}
+	for _, item := range req.Tools {
+		parameters, err := toolParameters(item.Function)
+		if err != nil {
+			return err
+		}
+		tool := ollamaTool{Type: string(openai.ToolTypeFunction)}
+		tool.Function.Name = item.Function.Name
+		tool.Function.Description = item.Function.Description
+		tool.Function.Parameters = parameters
+		body.Tools = append(body.Tools, tool)
+	}
+	return nil
+}
+
+// ollamaToolCalls 返回消息中的工具调用，Ollama不返回调用ID，这里生成
+func ollamaToolCalls(message ollamaMessage) []openai.ToolCall {
+	var calls []openai.ToolCall
+	for _, call := range message.ToolCalls {
+		calls = append(calls, newToolCall(call.Function.Name, call.Function.Arguments))
+	}
+	return calls
+}
+
+func ollamaFinishReason(reason string) openai.FinishReason {
+	if reason == "length" {
+		return openai.FinishReasonLength
+	}
+	return openai.FinishReasonStop
+}
+
+type ollamaStream struct {
+	response *http.Response
+	reader   *bufio.Reader
+	id       string
+	model    string
+	created  int64
+	finished bool
+	// toolCalls 已经返回的工具调用数
+	toolCalls int
+}
+
+func (s *ollamaStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	for !s.finished {
+		line, err := s.reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) == 0 {
+			if err == io.EOF {
+				// 连接在done之前断开，回答不完整
+				return openai.ChatCompletionStreamResponse{}, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return openai.ChatCompletionStreamResponse{}, err
+			}
+			continue
+		}
+		var data ollamaResponse
+		if err := json.Unmarshal(line, &data); err != nil {
+			return openai.ChatCompletionStreamResponse{}, err
+		}
+		if data.Error != "" {
+			return openai.ChatCompletionStreamResponse{}, &openai.APIError{
+				HTTPStatusCode: http.StatusBadGateway,
+				Message:        data.Error,
+			}
+		}
+		// 工具调用不会分片，每个调用完整地出现在一行中
+		toolCalls := ollamaToolCalls(data.Message)
+		for i := range toolCalls {
+			index := s.toolCalls
+			toolCalls[i].Index = &index
+			s.toolCalls++
+		}
+		var finishReason openai.FinishReason
+		if data.Done {
+			s.finished = true
+			finishReason = ollamaFinishReason(data.DoneReason)
+			if s.toolCalls > 0 {
+				finishReason = openai.FinishReasonToolCalls
+			}
+		}
+		return newChunk(s.id, s.model, s.created, 0, openai.ChatCompletionStreamChoiceDelta{
+			Role:      data.Message.Role,
+			Content:   data.Message.Content,
+			ToolCalls: toolCalls,
+		}, finishReason), nil
+	}
+	return openai.ChatCompletionStreamResponse{}, io.EOF
+}
+
+func (s *ollamaStream) Close() {
+	s.response.Body.Close()
+}
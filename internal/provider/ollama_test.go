@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestOllamaRequest(t *testing.T) {
+	url, rec := newTestServer(t, 200, "application/json", `{"model":"test-model","message":{"role":"assistant","content":"ok"},"done":true,"done_reason":"stop"}`)
+	req := toolConversation()
+	req.Temperature = 0.5
+	seed := 7
+	req.Seed = &seed
+	if _, err := NewOllamaProvider(url).CreateChatCompletion(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.path != "/api/chat" {
+		t.Fatalf("got path %s", rec.path)
+	}
+	var body ollamaRequest
+	rec.decode(t, &body)
+	if body.Stream || body.Options["temperature"] != 0.5 || body.Options["num_predict"] != float64(100) || body.Options["seed"] != float64(7) {
+		t.Fatalf("got stream %v options %v", body.Stream, body.Options)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Type != "function" || body.Tools[0].Function.Name != "calculator" {
+		t.Fatalf("got tools %+v", body.Tools)
+	}
+	assertJSON(t, body.Tools[0].Function.Parameters, `{"type":"object","properties":{"expression":{"type":"string"}}}`)
+
+	if len(body.Messages) != 6 {
+		t.Fatalf("got %d messages, want 6", len(body.Messages))
+	}
+	if body.Messages[0].Role != "system" || body.Messages[0].Content != "be brief" {
+		t.Fatalf("got system message %+v", body.Messages[0])
+	}
+	assistant := body.Messages[2]
+	if assistant.Role != "assistant" || len(assistant.ToolCalls) != 2 || assistant.ToolCalls[0].Function.Name != "calculator" {
+		t.Fatalf("got assistant message %+v", assistant)
+	}
+	// arguments是JSON对象而不是字符串
+	assertJSON(t, assistant.ToolCalls[0].Function.Arguments, `{"expression":"1+2"}`)
+	for i, content := range []string{"3", `{"result":12}`} {
+		message := body.Messages[3+i]
+		if message.Role != "tool" || message.ToolName != "calculator" || message.Content != content {
+			t.Fatalf("got tool message %+v", message)
+		}
+	}
+}
+
+func TestOllamaToolChoice(t *testing.T) {
+	var body ollamaRequest
+	req := toolConversation()
+	req.ToolChoice = "none"
+	if err := ollamaTools(&body, req); err != nil || len(body.Tools) != 0 {
+		t.Fatalf("got tools %+v err %v, want no tools", body.Tools, err)
+	}
+}
+
+func TestOllamaUnsupported(t *testing.T) {
+	cases := map[string]func(*openai.ChatCompletionRequest){
+		"n":        func(req *openai.ChatCompletionRequest) { req.N = 3 },
+		"required": func(req *openai.ChatCompletionRequest) { req.ToolChoice = "required" },
+		"function": func(req *openai.ChatCompletionRequest) {
+			req.ToolChoice = openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: "calculator"}}
+		},
+		"function message": func(req *openai.ChatCompletionRequest) {
+			req.Messages[3] = openai.ChatCompletionMessage{Role: openai.ChatMessageRoleFunction, Name: "calculator", Content: "3"}
+		},
+	}
+	for name, modify := range cases {
+		t.Run(name, func(t *testing.T) {
+			url, rec := newTestServer(t, 200, "application/json", "{}")
+			req := toolConversation()
+			modify(&req)
+			_, err := NewOllamaProvider(url).CreateChatCompletion(context.Background(), req)
+			assertAPIError(t, err, http.StatusBadRequest, "invalid_request_error", "")
+			if rec.requests != 0 {
+				t.Fatal("request was sent to upstream")
+			}
+		})
+	}
+}
+
+func TestOllamaResponse(t *testing.T) {
+	url, _ := newTestServer(t, 200, "application/json", `{
+		"model": "llama-test",
+		"message": {"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "calculator", "arguments": {"expression": "1+2"}}}]},
+		"done": true,
+		"done_reason": "stop",
+		"prompt_eval_count": 10,
+		"eval_count": 5
+	}`)
+	resp, err := NewOllamaProvider(url).CreateChatCompletion(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Model != "llama-test" || resp.Usage.TotalTokens != 15 {
+		t.Fatalf("got response %+v", resp)
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason != openai.FinishReasonToolCalls || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("got choice %+v", choice)
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.ID == "" || call.Function.Name != "calculator" {
+		t.Fatalf("got tool call %+v", call)
+	}
+	assertJSON(t, []byte(call.Function.Arguments), `{"expression":"1+2"}`)
+}
+
+const ollamaLines = `{"model":"llama-test","message":{"role":"assistant","content":"Let me "},"done":false}
+{"model":"llama-test","message":{"role":"assistant","content":"calculate."},"done":false}
+
+{"model":"llama-test","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"calculator","arguments":{"expression":"1+2"}}}]},"done":false}
+`
+
+func TestOllamaStream(t *testing.T) {
+	url, rec := newTestServer(t, 200, "application/x-ndjson", ollamaLines+
+		`{"model":"llama-test","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":10,"eval_count":5}
+`)
+	stream, err := NewOllamaProvider(url).CreateChatCompletionStream(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readStream(t, stream)
+	if err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+	var body ollamaRequest
+	rec.decode(t, &body)
+	if !body.Stream {
+		t.Fatal("stream is not set")
+	}
+	message := mergeChunks(chunks)
+	if message.content != "Let me calculate." || message.finishReason != openai.FinishReasonToolCalls {
+		t.Fatalf("got message %+v", message)
+	}
+	if len(message.toolCalls) != 1 || message.toolCalls[0].ID == "" || message.toolCalls[0].Function.Name != "calculator" {
+		t.Fatalf("got tool calls %+v", message.toolCalls)
+	}
+}
+
+func TestOllamaStreamDisconnect(t *testing.T) {
+	// 没有done为true的行，最后一行也没有换行
+	url, _ := newTestServer(t, 200, "application/x-ndjson", ollamaLines+`{"model":"llama-test","message":{"role":"assistant","content":"!"},"done":false}`)
+	stream, err := NewOllamaProvider(url).CreateChatCompletionStream(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readStream(t, stream)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if mergeChunks(chunks).content != "Let me calculate.!" {
+		t.Fatal("chunks before the disconnect are lost")
+	}
+}
+
+func TestOllamaStreamError(t *testing.T) {
+	url, _ := newTestServer(t, 200, "application/x-ndjson", `{"error":"model runner has unexpectedly stopped"}
+`)
+	stream, err := NewOllamaProvider(url).CreateChatCompletionStream(context.Background(), toolConversation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = readStream(t, stream)
+	assertAPIError(t, err, http.StatusBadGateway, "", "model runner has unexpectedly stopped")
+}
+
+func TestOllamaHTTPError(t *testing.T) {
+	url, _ := newTestServer(t, 404, "application/json", `{"error":"model \"test-model\" not found, try pulling it first"}`)
+	_, err := NewOllamaProvider(url).CreateChatCompletion(context.Background(), toolConversation())
+	assertAPIError(t, err, 404, "", `model "test-model" not found, try pulling it first`)
+}
@@ -0,0 +1,35 @@
+package provider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAIProvider 兼容OpenAI接口的上游
+type OpenAIProvider struct {
+	client  *openai.Client
+	baseURL string
+	apiKey  string
+}
+
+func NewOpenAIProvider(baseURL, apiKey string) *OpenAIProvider {
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	return &OpenAIProvider{
+		client:  openai.NewClientWithConfig(config),
+		baseURL: strings.TrimRight(config.BaseURL, "/"),
+		apiKey:  apiKey,
+	}
+}
+
+func (p *OpenAIProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	return p.client.CreateChatCompletion(ctx, req)
+}
+
+func (p *OpenAIProvider) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error) {
+	return p.client.CreateChatCompletionStream(ctx, req)
+}
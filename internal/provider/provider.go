@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	TypeOpenAI    = "openai"
+	TypeAnthropic = "anthropic"
+	TypeGemini    = "gemini"
+	TypeOllama    = "ollama"
+)
+
+// Provider 上游模型服务，请求和响应统一使用OpenAI的格式
+type Provider interface {
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (Stream, error)
+}
+
+// Stream 流式响应，读到上游的结束标志后Recv返回io.EOF，
+// 连接在结束标志之前断开时返回io.ErrUnexpectedEOF
+type Stream interface {
+	Recv() (openai.ChatCompletionStreamResponse, error)
+	Close()
+}
+
+// New 根据类型创建Provider，baseURL为空时使用各服务商的默认地址
+func New(typ, baseURL, apiKey string) (Provider, error) {
+	switch typ {
+	case "", TypeOpenAI:
+		return NewOpenAIProvider(baseURL, apiKey), nil
+	case TypeAnthropic:
+		return NewAnthropicProvider(baseURL, apiKey), nil
+	case TypeGemini:
+		return NewGeminiProvider(baseURL, apiKey), nil
+	case TypeOllama:
+		return NewOllamaProvider(baseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", typ)
+	}
+}
@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"net/url"
+
+	"github.com/coxlong/eureka/internal/pkg/config"
+)
+
+const (
+	KeySourceRequest = "request"
+	KeySourceUser    = "user"
+	KeySourceOrg     = "org"
+)
+
+// officialHosts 各服务商的官方地址，未配置KeyName的上游只有使用这些地址时才能使用用户密钥
+var officialHosts = map[string]string{
+	TypeOpenAI:    "api.openai.com",
+	TypeAnthropic: "api.anthropic.com",
+	TypeGemini:    "generativelanguage.googleapis.com",
+}
+
+// VaultKeyName 返回上游在用户密钥库中的密钥名称，返回空字符串表示该上游不使用用户密钥
+func VaultKeyName(upstream config.Upstream) string {
+	if upstream.KeyName != "" {
+		return upstream.KeyName
+	}
+	host, ok := officialHosts[upstream.Provider]
+	if !ok {
+		return ""
+	}
+	if upstream.BaseURL == "" {
+		return upstream.Provider
+	}
+	if u, err := url.Parse(upstream.BaseURL); err == nil && u.Scheme == "https" && u.Host == host {
+		return upstream.Provider
+	}
+	return ""
+}
+
+// Registry 模型路由表，把客户端请求的模型名称解析到具体的上游
+type Registry struct {
+	defaultUpstream config.Upstream
+	models          []config.Model
+	index           map[string]int
+}
+
+func NewRegistry(cfg *config.OpenAI) *Registry {
+	r := &Registry{
+		defaultUpstream: config.Upstream{
+			Provider:  cfg.Provider,
+			BaseURL:   cfg.BaseURL,
+			KeySource: cfg.KeySource,
+			KeyName:   cfg.KeyName,
+			APIKey:    cfg.APIKey,
+		},
+		index: map[string]int{},
+	}
+	if r.defaultUpstream.Provider == "" {
+		r.defaultUpstream.Provider = TypeOpenAI
+	}
+	for _, item := range cfg.Models {
+		item.Upstream = r.withDefaults(item.Upstream, item.Name)
+		fallbacks := make([]config.Upstream, len(item.Fallbacks))
+		for i, fallback := range item.Fallbacks {
+			fallbacks[i] = r.withDefaults(fallback, item.Name)
+		}
+		item.Fallbacks = fallbacks
+		r.index[item.Name] = len(r.models)
+		r.models = append(r.models, item)
+	}
+	return r
+}
+
+// Resolve 返回模型对应的路由，未配置路由表时所有模型都使用默认上游
+func (r *Registry) Resolve(name string) (*config.Model, bool) {
+	if len(r.models) == 0 {
+		upstream := r.defaultUpstream
+		upstream.ModelID = name
+		return &config.Model{
+			Name:     name,
+			Upstream: upstream,
+		}, true
+	}
+	i, ok := r.index[name]
+	if !ok {
+		return nil, false
+	}
+	route := r.models[i]
+	return &route, true
+}
+
+func (r *Registry) Models() []config.Model {
+	return r.models
+}
+
+// Upstreams 返回模型的主上游和备用上游，按尝试顺序排列
+func Upstreams(route *config.Model) []config.Upstream {
+	return append([]config.Upstream{route.Upstream}, route.Fallbacks...)
+}
+
+// withDefaults 补全上游配置，服务商类型与默认上游一致时继承其地址和组织密钥
+func (r *Registry) withDefaults(upstream config.Upstream, name string) config.Upstream {
+	if upstream.Provider == "" {
+		upstream.Provider = r.defaultUpstream.Provider
+	}
+	if upstream.Provider == r.defaultUpstream.Provider {
+		if upstream.BaseURL == "" {
+			upstream.BaseURL = r.defaultUpstream.BaseURL
+		}
+		if upstream.APIKey == "" {
+			upstream.APIKey = r.defaultUpstream.APIKey
+		}
+	}
+	if upstream.ModelID == "" {
+		upstream.ModelID = name
+	}
+	return upstream
+}
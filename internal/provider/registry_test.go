@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/coxlong/eureka/internal/pkg/config"
+)
+
+func TestRegistryDefaultUpstream(t *testing.T) {
+	r := NewRegistry(&config.OpenAI{BaseURL: "https://proxy.example.com/v1", KeySource: KeySourceRequest, KeyName: "proxy", APIKey: "org"})
+	route, ok := r.Resolve("gpt-4o")
+	if !ok {
+		t.Fatal("model should resolve without a routing table")
+	}
+	want := config.Upstream{
+		Provider:  TypeOpenAI,
+		BaseURL:   "https://proxy.example.com/v1",
+		ModelID:   "gpt-4o",
+		KeySource: KeySourceRequest,
+		KeyName:   "proxy",
+		APIKey:    "org",
+	}
+	if route.Upstream != want {
+		t.Fatalf("got %+v, want %+v", route.Upstream, want)
+	}
+	if name := VaultKeyName(route.Upstream); name != "proxy" {
+		t.Fatalf("got vault key name %q, want proxy", name)
+	}
+
+	// 路由表中的上游只继承地址和组织密钥，密钥来源由各自的配置决定
+	r = NewRegistry(&config.OpenAI{KeySource: KeySourceRequest, APIKey: "org", Models: []config.Model{{Name: "gpt"}}})
+	route, _ = r.Resolve("gpt")
+	if route.KeySource != "" || route.APIKey != "org" {
+		t.Fatalf("got %+v", route.Upstream)
+	}
+	if _, ok := r.Resolve("other"); ok {
+		t.Fatal("unknown model should not resolve with a routing table")
+	}
+}
+
+func TestVaultKeyName(t *testing.T) {
+	cases := []struct {
+		upstream config.Upstream
+		want     string
+	}{
+		{config.Upstream{Provider: TypeOpenAI}, TypeOpenAI},
+		{config.Upstream{Provider: TypeOpenAI, BaseURL: "https://api.openai.com/v1"}, TypeOpenAI},
+		{config.Upstream{Provider: TypeOpenAI, BaseURL: "http://api.openai.com/v1"}, ""},
+		{config.Upstream{Provider: TypeOpenAI, BaseURL: "https://proxy.example.com/v1"}, ""},
+		{config.Upstream{Provider: TypeOpenAI, BaseURL: "https://proxy.example.com/v1", KeyName: "proxy"}, "proxy"},
+		{config.Upstream{Provider: TypeOllama}, ""},
+	}
+	for _, c := range cases {
+		if got := VaultKeyName(c.upstream); got != c.want {
+			t.Errorf("VaultKeyName(%+v) = %q, want %q", c.upstream, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+// 非OpenAI上游的tool_choice，由OpenAI格式的none/auto/required/指定函数转换而来
+const (
+	toolChoiceNone     = "none"
+	toolChoiceAuto     = "auto"
+	toolChoiceRequired = "required"
+	toolChoiceFunction = "function"
+)
+
+func invalidRequest(format string, args ...any) *openai.APIError {
+	return &openai.APIError{
+		HTTPStatusCode: http.StatusBadRequest,
+		Type:           "invalid_request_error",
+		Message:        fmt.Sprintf(format, args...),
+	}
+}
+
+// checkUnsupported 检查上游无法按OpenAI语义处理的参数，返回400而不是静默丢弃
+func checkUnsupported(provider string, req openai.ChatCompletionRequest, multipleChoices bool) error {
+	if req.N > 1 && !multipleChoices {
+		return invalidRequest("n > 1 is not supported by %s models", provider)
+	}
+	if len(req.Functions) > 0 || req.FunctionCall != nil {
+		return invalidRequest("functions is not supported by %s models, use tools instead", provider)
+	}
+	for _, item := range req.Messages {
+		if item.FunctionCall != nil || item.Role == openai.ChatMessageRoleFunction {
+			return invalidRequest("function messages are not supported by %s models, use tool messages instead", provider)
+		}
+	}
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != openai.ToolTypeFunction {
+			return invalidRequest("tool type %s is not supported by %s models", tool.Type, provider)
+		}
+	}
+	return nil
+}
+
+// parseToolChoice 返回tool_choice的类型，指定函数时同时返回函数名。未设置时为auto
+func parseToolChoice(choice any) (string, string, error) {
+	if choice == nil {
+		return toolChoiceAuto, "", nil
+	}
+	data, err := json.Marshal(choice)
+	if err != nil {
+		return "", "", invalidRequest("invalid tool_choice: %s", err)
+	}
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		switch mode {
+		case toolChoiceNone, toolChoiceAuto, toolChoiceRequired:
+			return mode, "", nil
+		}
+		return "", "", invalidRequest("invalid tool_choice %q", mode)
+	}
+	var function openai.ToolChoice
+	if err := json.Unmarshal(data, &function); err != nil || function.Function.Name == "" {
+		return "", "", invalidRequest("invalid tool_choice %s", data)
+	}
+	return toolChoiceFunction, function.Function.Name, nil
+}
+
+// toolParameters 返回函数参数的JSON Schema，未定义时为不带参数的object
+func toolParameters(definition openai.FunctionDefinition) (json.RawMessage, error) {
+	if definition.Parameters == nil {
+		return json.RawMessage(`{"type":"object","properties":{}}`), nil
+	}
+	data, err := json.Marshal(definition.Parameters)
+	if err != nil {
+		return nil, invalidRequest("invalid parameters of tool %s: %s", definition.Name, err)
+	}
+	if string(data) == "null" {
+		return json.RawMessage(`{"type":"object","properties":{}}`), nil
+	}
+	return data, nil
+}
+
+// toolArguments 把tool_calls中字符串形式的arguments转换为JSON对象
+func toolArguments(call openai.ToolCall) (json.RawMessage, error) {
+	if call.Function.Arguments == "" {
+		return json.RawMessage("{}"), nil
+	}
+	if !json.Valid([]byte(call.Function.Arguments)) {
+		return nil, invalidRequest("arguments of tool call %s is not valid JSON", call.ID)
+	}
+	return json.RawMessage(call.Function.Arguments), nil
+}
+
+// toolCallNames 返回assistant消息中工具调用ID到函数名的映射，用于只按函数名关联结果的上游
+func toolCallNames(messages []openai.ChatCompletionMessage) map[string]string {
+	names := map[string]string{}
+	for _, item := range messages {
+		for _, call := range item.ToolCalls {
+			names[call.ID] = call.Function.Name
+		}
+	}
+	return names
+}
+
+// newToolCall 为不返回调用ID的上游生成OpenAI格式的工具调用
+func newToolCall(name string, arguments json.RawMessage) openai.ToolCall {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+	return openai.ToolCall{
+		ID:   "call_" + uuid.NewString(),
+		Type: openai.ToolTypeFunction,
+		Function: openai.FunctionCall{
+			Name:      name,
+			Arguments: string(arguments),
+		},
+	}
+}
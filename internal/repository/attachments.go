@@ -0,0 +1,11 @@
+package repository
+
+import "github.com/coxlong/eureka/internal/model"
+
+type AttachmentsRepo interface {
+	// CreateAttachment 保存附件信息，key为文件在存储中的位置
+	CreateAttachment(uid, key string, attachment *model.Attachment) error
+	// GetAttachment 返回用户的附件和它在存储中的位置
+	GetAttachment(uid, id string) (*model.Attachment, string, error)
+	DeleteAttachment(uid, id string) error
+}
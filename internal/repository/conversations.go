@@ -1,12 +1,38 @@
 package repository
 
-import "github.com/coxlong/eureka/internal/model"
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+)
 
 type ConversationsRepo interface {
 	CreateConversation(uid string, meta *model.ConversationMeta) error
 	UpdateConversation(uid string, meta *model.ConversationMeta) error
 	GetConversationByID(id string, uid string) (*model.ConversationMeta, []model.Message, error)
-	GetConversations(uid string) ([]model.ConversationMeta, error)
+	// GetConversations 按(pinned, updated_at, id)降序返回用户符合筛选条件的会话
+	GetConversations(uid string, filter *model.ConversationFilter) ([]model.ConversationMeta, error)
 	CreateMessages(conversationID string, messages []model.Message) error
+	UpdateCollection(uid, cid, collectionID string) error
+	UpdatePinned(uid, cid string, pinned bool) error
+	UpdateArchived(uid, cid string, archived bool) error
+	// SetTags 用tags替换会话的标签
+	SetTags(uid, cid string, tags []string) error
+	// GetMessagesByIDs 返回用户会话中指定ID的消息，不存在或属于其他用户的ID被忽略
+	GetMessagesByIDs(uid string, ids []string) ([]model.ConversationMessage, error)
+	// SearchMessages 返回用户会话中内容包含所有关键词的用户和助手消息
+	SearchMessages(uid string, terms []string, limit int) ([]model.ConversationMessage, error)
+	// SearchTitles 返回标题包含所有关键词的会话
+	SearchTitles(uid string, terms []string, limit int) ([]model.ConversationMeta, error)
+	// DeleteConversations 把用户的会话连同消息移入回收站，返回实际删除的会话ID
+	DeleteConversations(uid string, ids []string) ([]string, error)
+	// GetDeletedConversations 按(deleted_at, id)降序返回回收站中after之后的会话，limit为0时不限制数量
+	GetDeletedConversations(uid string, after *model.TrashCursor, limit int) ([]model.ConversationMeta, error)
+	// RestoreConversations 从回收站恢复会话以及随之删除的消息，返回实际恢复的会话ID
+	RestoreConversations(uid string, ids []string) ([]string, error)
+	// PurgeConversations 永久删除回收站中的会话、消息和标签，返回实际删除的会话ID
+	PurgeConversations(uid string, ids []string) ([]string, error)
+	// GetDeletedBefore 返回在before之前移入回收站的会话ID，按用户分组，最多limit个
+	GetDeletedBefore(before time.Time, limit int) (map[string][]string, error)
 	Transaction(txFunc func(r ConversationsRepo) error) error
 }
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"gorm.io/gorm"
+)
+
+type Attachment struct {
+	ID          string `gorm:"primarykey;type:char(36)"`
+	UID         string `gorm:"index;type:varchar(64)"`
+	StorageKey  string `gorm:"type:varchar(255);NOT NULL"`
+	Name        string `gorm:"type:varchar(255)"`
+	ContentType string `gorm:"type:varchar(64)"`
+	Size        int64
+	CreatedAt   time.Time
+}
+
+func NewGormAttachmentsRepository(db *gorm.DB) (AttachmentsRepo, error) {
+	err := db.AutoMigrate(&Attachment{})
+	if err != nil {
+		return nil, err
+	}
+	return &GormAttachmentsRepository{db}, nil
+}
+
+type GormAttachmentsRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormAttachmentsRepository) CreateAttachment(uid, key string, attachment *model.Attachment) error {
+	params := Attachment{
+		ID:          attachment.ID,
+		UID:         uid,
+		StorageKey:  key,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+	}
+	if err := r.db.Create(&params).Error; err != nil {
+		return err
+	}
+	attachment.CreatedAt = params.CreatedAt
+	return nil
+}
+
+func (r *GormAttachmentsRepository) GetAttachment(uid, id string) (*model.Attachment, string, error) {
+	var attachment Attachment
+	tx := r.db.Where(Attachment{ID: id, UID: uid}).First(&attachment)
+	if tx.Error != nil {
+		return nil, "", tx.Error
+	}
+	return &model.Attachment{
+		ID:          attachment.ID,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   attachment.CreatedAt,
+	}, attachment.StorageKey, nil
+}
+
+func (r *GormAttachmentsRepository) DeleteAttachment(uid, id string) error {
+	return r.db.Where(Attachment{ID: id, UID: uid}).Delete(&Attachment{}).Error
+}
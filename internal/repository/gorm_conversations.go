@@ -8,13 +8,24 @@ import (
 )
 
 type Message struct {
-	ID             string `gorm:"primarykey;type:char(36)"`
-	ConversationID string `gorm:"primarykey;type:char(36)"`
-	Parent         string `gorm:"type:char(36)"`
-	Role           string `gorm:"type:char(9);NOT NULL"`
-	Content        string
-	CreatedAt      time.Time
-	DeletedAt      gorm.DeletedAt `gorm:"index"`
+	ID               string `gorm:"primarykey;type:char(36)"`
+	ConversationID   string `gorm:"primarykey;type:char(36)"`
+	Parent           string `gorm:"type:char(36)"`
+	Role             string `gorm:"type:char(9);NOT NULL"`
+	Content          string
+	MultiContent     []model.ContentPart `gorm:"serializer:json"`
+	Name             string              `gorm:"type:varchar(64)"`
+	FunctionCall     *model.FunctionCall `gorm:"serializer:json"`
+	ToolCalls        []model.ToolCall    `gorm:"serializer:json"`
+	ToolCallID       string              `gorm:"type:varchar(64)"`
+	Citations        []model.Citation    `gorm:"serializer:json"`
+	Upstream         string              `gorm:"type:varchar(128)"`
+	Status           string              `gorm:"type:varchar(16)"`
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	CreatedAt        time.Time
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
 }
 
 type Conversation struct {
@@ -25,22 +36,33 @@ type Conversation struct {
 	MaxTokens     int       `gorm:"type:INT"`
 	Temperature   float32   `gorm:"type:FLOAT"`
 	CurrentNodeID string    `gorm:"type:char(36)"`
+	CollectionID  string    `gorm:"type:char(36)"`
+	Pinned        bool      `gorm:"NOT NULL;default:false"`
+	Archived      bool      `gorm:"NOT NULL;default:false"`
 	Messages      []Message `gorm:"foreignKey:ConversationID"`
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	DeletedAt     gorm.DeletedAt `gorm:"index"`
 }
 
+// ConversationTag 会话的标签，一个会话可以有多个标签
+type ConversationTag struct {
+	ConversationID string `gorm:"primarykey;type:char(36)"`
+	Tag            string `gorm:"primarykey;type:varchar(64);index"`
+}
+
 func NewGormConversationRepository(db *gorm.DB) (ConversationsRepo, error) {
-	err := db.AutoMigrate(&Conversation{}, &Message{})
+	err := db.AutoMigrate(&Conversation{}, &Message{}, &ConversationTag{})
 	if err != nil {
 		return nil, err
 	}
-	return &GormConversationRepository{db}, nil
+	return &GormConversationRepository{db: db, fullText: setupFullText(db)}, nil
 }
 
 type GormConversationRepository struct {
 	db *gorm.DB
+	// fullText 消息全文索引的实现，为空时搜索使用LIKE
+	fullText string
 }
 
 func (r *GormConversationRepository) CreateConversation(uid string, meta *model.ConversationMeta) error {
@@ -52,10 +74,12 @@ func (r *GormConversationRepository) CreateConversation(uid string, meta *model.
 		MaxTokens:     meta.MaxTokens,
 		Temperature:   meta.Temperature,
 		CurrentNodeID: meta.CurrentNodeID,
+		CollectionID:  meta.CollectionID,
 	}
 	return r.db.Create(&params).Error
 }
 
+// UpdateConversation 只更新非零值的字段，CollectionID为空时保留原来关联的知识库
 func (r *GormConversationRepository) UpdateConversation(uid string, meta *model.ConversationMeta) error {
 	params := Conversation{
 		ID:            meta.ID,
@@ -65,6 +89,7 @@ func (r *GormConversationRepository) UpdateConversation(uid string, meta *model.
 		MaxTokens:     meta.MaxTokens,
 		Temperature:   meta.Temperature,
 		CurrentNodeID: meta.CurrentNodeID,
+		CollectionID:  meta.CollectionID,
 	}
 	return r.db.Updates(&params).Error
 }
@@ -84,27 +109,79 @@ func (r *GormConversationRepository) GetConversationByID(id string, uid string)
 		MaxTokens:     conversation.MaxTokens,
 		Temperature:   conversation.Temperature,
 		CurrentNodeID: conversation.CurrentNodeID,
+		CollectionID:  conversation.CollectionID,
+		Pinned:        conversation.Pinned,
+		Archived:      conversation.Archived,
 		CreatedAt:     conversation.CreatedAt,
 		UpdatedAt:     conversation.UpdatedAt,
 	}
+	tags, err := r.getTags([]string{conversation.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	result.Tags = tags[conversation.ID]
 	messages := []model.Message{}
 	for _, item := range conversation.Messages {
 		messages = append(messages, model.Message{
-			ID:        item.ID,
-			Parent:    item.Parent,
-			Role:      item.Role,
-			Content:   item.Content,
-			CreatedAt: item.CreatedAt,
+			ID:               item.ID,
+			Parent:           item.Parent,
+			Role:             item.Role,
+			Content:          item.Content,
+			MultiContent:     item.MultiContent,
+			Name:             item.Name,
+			FunctionCall:     item.FunctionCall,
+			ToolCalls:        item.ToolCalls,
+			ToolCallID:       item.ToolCallID,
+			Citations:        item.Citations,
+			Upstream:         item.Upstream,
+			Status:           item.Status,
+			PromptTokens:     item.PromptTokens,
+			CompletionTokens: item.CompletionTokens,
+			Cost:             item.Cost,
+			CreatedAt:        item.CreatedAt,
 		})
 	}
 	return &result, messages, nil
 }
 
-func (r *GormConversationRepository) GetConversations(uid string) ([]model.ConversationMeta, error) {
+func (r *GormConversationRepository) GetConversations(uid string, filter *model.ConversationFilter) ([]model.ConversationMeta, error) {
+	tx := r.db.Where(Conversation{UID: uid})
+	if filter.Model != "" {
+		tx = tx.Where("model = ?", filter.Model)
+	}
+	if filter.Tag != "" {
+		tx = tx.Where("EXISTS (SELECT 1 FROM conversation_tags WHERE conversation_tags.conversation_id = conversations.id AND conversation_tags.tag = ?)", filter.Tag)
+	}
+	if filter.Pinned != nil {
+		tx = tx.Where("pinned = ?", *filter.Pinned)
+	}
+	if filter.Archived != nil {
+		tx = tx.Where("archived = ?", *filter.Archived)
+	}
+	if !filter.From.IsZero() {
+		tx = tx.Where("updated_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where("updated_at < ?", filter.To)
+	}
+	if after := filter.After; after != nil {
+		tx = tx.Where("pinned < ? OR (pinned = ? AND (updated_at < ? OR (updated_at = ? AND id < ?)))",
+			after.Pinned, after.Pinned, after.UpdatedAt, after.UpdatedAt, after.ID)
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
 	var conversations []Conversation
-	tx := r.db.Where(Conversation{UID: uid}).Order("updated_at DESC").Find(&conversations)
-	if tx.Error != nil {
-		return nil, tx.Error
+	if err := tx.Order("pinned DESC, updated_at DESC, id DESC").Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(conversations))
+	for i, item := range conversations {
+		ids[i] = item.ID
+	}
+	tags, err := r.getTags(ids)
+	if err != nil {
+		return nil, err
 	}
 	result := []model.ConversationMeta{}
 	for _, item := range conversations {
@@ -115,6 +192,10 @@ func (r *GormConversationRepository) GetConversations(uid string) ([]model.Conve
 			MaxTokens:     item.MaxTokens,
 			Temperature:   item.Temperature,
 			CurrentNodeID: item.CurrentNodeID,
+			CollectionID:  item.CollectionID,
+			Tags:          tags[item.ID],
+			Pinned:        item.Pinned,
+			Archived:      item.Archived,
 			CreatedAt:     item.CreatedAt,
 			UpdatedAt:     item.UpdatedAt,
 		})
@@ -122,22 +203,139 @@ func (r *GormConversationRepository) GetConversations(uid string) ([]model.Conve
 	return result, nil
 }
 
+// getTags 返回会话ID到标签的映射，标签按字母顺序排列
+func (r *GormConversationRepository) getTags(ids []string) (map[string][]string, error) {
+	result := map[string][]string{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var tags []ConversationTag
+	if err := r.db.Where("conversation_id IN ?", ids).Order("tag").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range tags {
+		result[item.ConversationID] = append(result[item.ConversationID], item.Tag)
+	}
+	return result, nil
+}
+
 func (r *GormConversationRepository) CreateMessages(conversationID string, messages []model.Message) error {
 	var params []Message
 	for _, item := range messages {
 		params = append(params, Message{
-			ID:             item.ID,
-			Parent:         item.Parent,
-			ConversationID: conversationID,
-			Role:           item.Role,
-			Content:        item.Content,
+			ID:               item.ID,
+			Parent:           item.Parent,
+			ConversationID:   conversationID,
+			Role:             item.Role,
+			Content:          item.Content,
+			MultiContent:     item.MultiContent,
+			Name:             item.Name,
+			FunctionCall:     item.FunctionCall,
+			ToolCalls:        item.ToolCalls,
+			ToolCallID:       item.ToolCallID,
+			Citations:        item.Citations,
+			Upstream:         item.Upstream,
+			Status:           item.Status,
+			PromptTokens:     item.PromptTokens,
+			CompletionTokens: item.CompletionTokens,
+			Cost:             item.Cost,
 		})
 	}
 	return r.db.CreateInBatches(params, 100).Error
 }
 
+// UpdateCollection 修改会话关联的知识库，collectionID为空时取消关联
+func (r *GormConversationRepository) UpdateCollection(uid, cid, collectionID string) error {
+	tx := r.db.Model(&Conversation{}).Where(Conversation{ID: cid, UID: uid}).Update("collection_id", collectionID)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdatePinned 置顶或取消置顶，不修改updated_at
+func (r *GormConversationRepository) UpdatePinned(uid, cid string, pinned bool) error {
+	return r.updateFlag(uid, cid, "pinned", pinned)
+}
+
+// UpdateArchived 归档或取消归档，不修改updated_at
+func (r *GormConversationRepository) UpdateArchived(uid, cid string, archived bool) error {
+	return r.updateFlag(uid, cid, "archived", archived)
+}
+
+func (r *GormConversationRepository) updateFlag(uid, cid, column string, value bool) error {
+	tx := r.db.Model(&Conversation{}).Where(Conversation{ID: cid, UID: uid}).UpdateColumn(column, value)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *GormConversationRepository) SetTags(uid, cid string, tags []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Conversation{}).Where(Conversation{ID: cid, UID: uid}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where(ConversationTag{ConversationID: cid}).Delete(&ConversationTag{}).Error; err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		params := make([]ConversationTag, len(tags))
+		for i, tag := range tags {
+			params[i] = ConversationTag{ConversationID: cid, Tag: tag}
+		}
+		return tx.Create(&params).Error
+	})
+}
+
+func (r *GormConversationRepository) GetMessagesByIDs(uid string, ids []string) ([]model.ConversationMessage, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var messages []struct {
+		Message
+		Title string
+	}
+	tx := r.db.Model(&Message{}).
+		Select("messages.*, conversations.title AS title").
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id AND conversations.deleted_at IS NULL").
+		Where("conversations.uid = ? AND messages.id IN ?", uid, ids).
+		Find(&messages)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	result := make([]model.ConversationMessage, 0, len(messages))
+	for _, item := range messages {
+		result = append(result, model.ConversationMessage{
+			ConversationID: item.ConversationID,
+			Title:          item.Title,
+			Message: model.Message{
+				ID:           item.ID,
+				Parent:       item.Parent,
+				Role:         item.Role,
+				Content:      item.Content,
+				MultiContent: item.MultiContent,
+				CreatedAt:    item.CreatedAt,
+			},
+		})
+	}
+	return result, nil
+}
+
 func (r *GormConversationRepository) Transaction(txFunc func(r ConversationsRepo) error) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		return txFunc(&GormConversationRepository{tx})
+		return txFunc(&GormConversationRepository{db: tx, fullText: r.fullText})
 	})
 }
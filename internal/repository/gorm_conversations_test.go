@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	if _, err := log.InitLogger(config.Development, &config.Logger{Filename: t.TempDir() + "/test.log", Level: "error"}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每个连接都是独立的内存数据库，只使用一个连接
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+type testConversation struct {
+	id        string
+	uid       string
+	updatedAt time.Time
+	pinned    bool
+	archived  bool
+	tags      []string
+}
+
+// newConversationsRepo 创建会话并设置更新时间、置顶、归档和标签
+func newConversationsRepo(t *testing.T, conversations []testConversation) ConversationsRepo {
+	db := newTestDB(t)
+	repo, err := NewGormConversationRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range conversations {
+		if err := repo.CreateConversation(c.uid, &model.ConversationMeta{ID: c.id, Title: c.id, Model: "gpt-4"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.SetTags(c.uid, c.id, c.tags); err != nil {
+			t.Fatal(err)
+		}
+		err := db.Model(&Conversation{}).Where("id = ?", c.id).UpdateColumns(map[string]any{
+			"updated_at": c.updatedAt,
+			"pinned":     c.pinned,
+			"archived":   c.archived,
+		}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+// listPages 按limit逐页读取会话，下一页从上一页最后一个会话的cursor开始
+func listPages(t *testing.T, repo ConversationsRepo, filter model.ConversationFilter) []string {
+	var pages []string
+	for len(pages) <= 10 {
+		page, err := repo.GetConversations("u1", &filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(page))
+		for i, item := range page {
+			ids[i] = item.ID
+		}
+		pages = append(pages, strings.Join(ids, ","))
+		if len(page) < filter.Limit {
+			return pages
+		}
+		last := page[len(page)-1]
+		// 与接口一样经过编码和解析
+		cursor, err := model.ParseConversationCursor(model.ConversationCursor{Pinned: last.Pinned, UpdatedAt: last.UpdatedAt, ID: last.ID}.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		filter.After = cursor
+	}
+	t.Fatalf("pagination does not terminate: %v", pages)
+	return nil
+}
+
+func TestGetConversationsPagination(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	repo := newConversationsRepo(t, []testConversation{
+		{id: "c1", uid: "u1", updatedAt: now, tags: []string{"work"}},
+		{id: "c2", uid: "u1", updatedAt: now},
+		{id: "c3", uid: "u1", updatedAt: now, tags: []string{"work"}},
+		{id: "c4", uid: "u1", updatedAt: now.Add(-2 * time.Hour)},
+		{id: "p1", uid: "u1", updatedAt: now.Add(time.Hour), pinned: true, tags: []string{"work"}},
+		{id: "p2", uid: "u1", updatedAt: now.Add(time.Hour), pinned: true},
+		{id: "p3", uid: "u1", updatedAt: now.Add(-time.Hour), pinned: true},
+		// 归档的会话比其他会话更新，只在不筛选归档时出现
+		{id: "x1", uid: "u1", updatedAt: now.Add(2 * time.Hour), archived: true},
+		{id: "x2", uid: "u1", updatedAt: now, pinned: true, archived: true, tags: []string{"work"}},
+		{id: "o1", uid: "u2", updatedAt: now.Add(3 * time.Hour), pinned: true},
+	})
+	archived, unarchived, unpinned := true, false, false
+	cases := []struct {
+		name   string
+		filter model.ConversationFilter
+		want   []string
+	}{
+		{"all", model.ConversationFilter{}, []string{"p2,p1,x2,p3,x1,c3,c2,c1,c4"}},
+		// 相同updated_at的会话被分到不同页时按id继续
+		{"ties across pages", model.ConversationFilter{Archived: &unarchived, Limit: 2}, []string{"p2,p1", "p3,c3", "c2,c1", "c4"}},
+		{"pinned boundary", model.ConversationFilter{Archived: &unarchived, Limit: 3}, []string{"p2,p1,p3", "c3,c2,c1", "c4"}},
+		{"archived included", model.ConversationFilter{Limit: 4}, []string{"p2,p1,x2,p3", "x1,c3,c2,c1", "c4"}},
+		{"archived only", model.ConversationFilter{Archived: &archived, Limit: 1}, []string{"x2", "x1", ""}},
+		{"unpinned", model.ConversationFilter{Pinned: &unpinned, Archived: &unarchived, Limit: 2}, []string{"c3,c2", "c1,c4", ""}},
+		{"tag", model.ConversationFilter{Tag: "work", Archived: &unarchived, Limit: 1}, []string{"p1", "c3", "c1", ""}},
+		{"updated range", model.ConversationFilter{From: now.Add(-time.Hour), To: now.Add(time.Minute), Limit: 2}, []string{"x2,p3", "c3,c2", "c1"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.filter.Limit == 0 {
+				page, err := repo.GetConversations("u1", &c.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := make([]string, len(page))
+				for i, item := range page {
+					ids[i] = item.ID
+				}
+				if got := strings.Join(ids, ","); got != c.want[0] {
+					t.Fatalf("got %s, want %s", got, c.want[0])
+				}
+				return
+			}
+			if got := listPages(t, repo, c.filter); strings.Join(got, " | ") != strings.Join(c.want, " | ") {
+				t.Fatalf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestGetDeletedConversationsPagination(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	db := newTestDB(t)
+	repo, err := NewGormConversationRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletedAt := map[string]time.Time{"d1": now, "d2": now, "d3": now.Add(-time.Hour), "d4": now.Add(time.Hour)}
+	for _, id := range []string{"d1", "d2", "d3", "d4", "live"} {
+		repo.CreateConversation("u1", &model.ConversationMeta{ID: id})
+	}
+	if _, err := repo.DeleteConversations("u1", []string{"d1", "d2", "d3", "d4"}); err != nil {
+		t.Fatal(err)
+	}
+	for id, at := range deletedAt {
+		db.Unscoped().Model(&Conversation{}).Where("id = ?", id).UpdateColumn("deleted_at", at)
+	}
+
+	var pages []string
+	var after *model.TrashCursor
+	for {
+		page, err := repo.GetDeletedConversations("u1", after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(page))
+		for i, item := range page {
+			ids[i] = item.ID
+		}
+		pages = append(pages, strings.Join(ids, ","))
+		if len(page) < 2 {
+			break
+		}
+		last := page[len(page)-1]
+		if after, err = model.ParseTrashCursor(model.TrashCursor{DeletedAt: last.DeletedAt, ID: last.ID}.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(pages, " | "); got != "d4,d2 | d1,d3 | " {
+		t.Fatalf("got %s", got)
+	}
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProviderKey struct {
+	UID          string `gorm:"primarykey;type:varchar(64)"`
+	Provider     string `gorm:"primarykey;type:varchar(32)"`
+	EncryptedKey string `gorm:"type:text;NOT NULL"`
+	Hint         string `gorm:"type:varchar(32)"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func NewGormKeysRepository(db *gorm.DB) (KeysRepo, error) {
+	err := db.AutoMigrate(&ProviderKey{})
+	if err != nil {
+		return nil, err
+	}
+	return &GormKeysRepository{db}, nil
+}
+
+type GormKeysRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormKeysRepository) SaveKey(uid string, key *model.ProviderKey) error {
+	params := ProviderKey{
+		UID:          uid,
+		Provider:     key.Provider,
+		EncryptedKey: key.Key,
+		Hint:         key.Hint,
+	}
+	return r.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&params).Error
+}
+
+func (r *GormKeysRepository) GetKey(uid, provider string) (*model.ProviderKey, error) {
+	var key ProviderKey
+	tx := r.db.Where(ProviderKey{UID: uid, Provider: provider}).First(&key)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &model.ProviderKey{
+		Provider:  key.Provider,
+		Key:       key.EncryptedKey,
+		Hint:      key.Hint,
+		UpdatedAt: key.UpdatedAt,
+	}, nil
+}
+
+func (r *GormKeysRepository) GetKeys(uid string) ([]model.ProviderKey, error) {
+	var keys []ProviderKey
+	tx := r.db.Where(ProviderKey{UID: uid}).Order("provider").Find(&keys)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	result := []model.ProviderKey{}
+	for _, item := range keys {
+		result = append(result, model.ProviderKey{
+			Provider:  item.Provider,
+			Hint:      item.Hint,
+			UpdatedAt: item.UpdatedAt,
+		})
+	}
+	return result, nil
+}
+
+func (r *GormKeysRepository) DeleteKey(uid, provider string) error {
+	return r.db.Where(ProviderKey{UID: uid, Provider: provider}).Delete(&ProviderKey{}).Error
+}
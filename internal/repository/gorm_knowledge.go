@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"gorm.io/gorm"
+)
+
+type Collection struct {
+	ID          string `gorm:"primarykey;type:char(36)"`
+	UID         string `gorm:"index;type:varchar(64)"`
+	Name        string `gorm:"type:varchar(128);NOT NULL"`
+	Description string `gorm:"type:varchar(1024)"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type Document struct {
+	ID           string `gorm:"primarykey;type:char(36)"`
+	UID          string `gorm:"index;type:varchar(64)"`
+	CollectionID string `gorm:"index;type:char(36)"`
+	Name         string `gorm:"type:varchar(255)"`
+	ContentType  string `gorm:"type:varchar(64)"`
+	Size         int64
+	Status       string `gorm:"type:varchar(16)"`
+	Error        string `gorm:"type:varchar(1024)"`
+	Chunks       int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type Chunk struct {
+	ID           string `gorm:"primarykey;type:char(36)"`
+	CollectionID string `gorm:"index;type:char(36)"`
+	DocumentID   string `gorm:"index;type:char(36)"`
+	Index        int
+	Content      string `gorm:"type:text"`
+}
+
+func NewGormKnowledgeRepository(db *gorm.DB) (KnowledgeRepo, error) {
+	err := db.AutoMigrate(&Collection{}, &Document{}, &Chunk{})
+	if err != nil {
+		return nil, err
+	}
+	return &GormKnowledgeRepository{db}, nil
+}
+
+type GormKnowledgeRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormKnowledgeRepository) CreateCollection(uid string, collection *model.Collection) error {
+	params := Collection{
+		ID:          collection.ID,
+		UID:         uid,
+		Name:        collection.Name,
+		Description: collection.Description,
+	}
+	if err := r.db.Create(&params).Error; err != nil {
+		return err
+	}
+	collection.CreatedAt = params.CreatedAt
+	return nil
+}
+
+func (r *GormKnowledgeRepository) GetCollection(uid, id string) (*model.Collection, error) {
+	var collection Collection
+	tx := r.db.Where(Collection{ID: id, UID: uid}).First(&collection)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return toModelCollection(collection), nil
+}
+
+func (r *GormKnowledgeRepository) GetCollections(uid string) ([]model.Collection, error) {
+	var collections []Collection
+	tx := r.db.Where(Collection{UID: uid}).Order("created_at DESC").Find(&collections)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	result := []model.Collection{}
+	for _, item := range collections {
+		result = append(result, *toModelCollection(item))
+	}
+	return result, nil
+}
+
+func (r *GormKnowledgeRepository) DeleteCollection(uid, id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where(Collection{ID: id, UID: uid}).Delete(&Collection{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where(Document{CollectionID: id}).Delete(&Document{}).Error; err != nil {
+			return err
+		}
+		return tx.Where(Chunk{CollectionID: id}).Delete(&Chunk{}).Error
+	})
+}
+
+func (r *GormKnowledgeRepository) CreateDocument(uid string, document *model.Document) error {
+	params := Document{
+		ID:           document.ID,
+		UID:          uid,
+		CollectionID: document.CollectionID,
+		Name:         document.Name,
+		ContentType:  document.ContentType,
+		Size:         document.Size,
+		Status:       document.Status,
+	}
+	if err := r.db.Create(&params).Error; err != nil {
+		return err
+	}
+	document.CreatedAt = params.CreatedAt
+	return nil
+}
+
+func (r *GormKnowledgeRepository) UpdateDocument(document *model.Document) error {
+	return r.db.Model(&Document{ID: document.ID}).Updates(map[string]any{
+		"status": document.Status,
+		"error":  document.Error,
+		"chunks": document.Chunks,
+	}).Error
+}
+
+func (r *GormKnowledgeRepository) GetDocument(uid, id string) (*model.Document, error) {
+	var document Document
+	tx := r.db.Where(Document{ID: id, UID: uid}).First(&document)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return toModelDocument(document), nil
+}
+
+func (r *GormKnowledgeRepository) GetDocuments(uid, collectionID string) ([]model.Document, error) {
+	var documents []Document
+	tx := r.db.Where(Document{UID: uid, CollectionID: collectionID}).Order("created_at DESC").Find(&documents)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	result := []model.Document{}
+	for _, item := range documents {
+		result = append(result, *toModelDocument(item))
+	}
+	return result, nil
+}
+
+func (r *GormKnowledgeRepository) DeleteDocument(uid, id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where(Document{ID: id, UID: uid}).Delete(&Document{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where(Chunk{DocumentID: id}).Delete(&Chunk{}).Error
+	})
+}
+
+func (r *GormKnowledgeRepository) CreateChunks(chunks []model.Chunk) error {
+	params := make([]Chunk, 0, len(chunks))
+	for _, item := range chunks {
+		params = append(params, Chunk{
+			ID:           item.ID,
+			CollectionID: item.CollectionID,
+			DocumentID:   item.DocumentID,
+			Index:        item.Index,
+			Content:      item.Content,
+		})
+	}
+	return r.db.CreateInBatches(params, 100).Error
+}
+
+func (r *GormKnowledgeRepository) GetChunks(collectionID string, ids []string) ([]model.RetrievedChunk, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var chunks []struct {
+		Chunk
+		DocumentName string
+	}
+	tx := r.db.Model(&Chunk{}).
+		Select("chunks.*, documents.name AS document_name").
+		Joins("JOIN documents ON documents.id = chunks.document_id").
+		Where("chunks.collection_id = ? AND chunks.id IN ?", collectionID, ids).
+		Find(&chunks)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	result := make([]model.RetrievedChunk, 0, len(chunks))
+	for _, item := range chunks {
+		result = append(result, model.RetrievedChunk{
+			Chunk: model.Chunk{
+				ID:           item.ID,
+				CollectionID: item.CollectionID,
+				DocumentID:   item.DocumentID,
+				Index:        item.Index,
+				Content:      item.Content,
+			},
+			DocumentName: item.DocumentName,
+		})
+	}
+	return result, nil
+}
+
+func toModelCollection(collection Collection) *model.Collection {
+	return &model.Collection{
+		ID:          collection.ID,
+		Name:        collection.Name,
+		Description: collection.Description,
+		CreatedAt:   collection.CreatedAt,
+	}
+}
+
+func toModelDocument(document Document) *model.Document {
+	return &model.Document{
+		ID:           document.ID,
+		CollectionID: document.CollectionID,
+		Name:         document.Name,
+		ContentType:  document.ContentType,
+		Size:         document.Size,
+		Status:       document.Status,
+		Error:        document.Error,
+		Chunks:       document.Chunks,
+		CreatedAt:    document.CreatedAt,
+	}
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RateCounter struct {
+	CounterKey string `gorm:"primarykey;type:varchar(191)"`
+	Hits       int64
+	ExpiresAt  time.Time `gorm:"index"`
+}
+
+// QuotaOverride 用户的限额，存在时替代配置中对应模型的默认限额
+type QuotaOverride struct {
+	UID               string `gorm:"primarykey;type:varchar(64)"`
+	Model             string `gorm:"primarykey;type:varchar(64)"`
+	RequestsPerMinute int
+	TokensPerDay      int
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func NewGormQuotaRepository(db *gorm.DB) (QuotaRepo, error) {
+	err := db.AutoMigrate(&RateCounter{}, &QuotaOverride{})
+	if err != nil {
+		return nil, err
+	}
+	return &GormQuotaRepository{db}, nil
+}
+
+type GormQuotaRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormQuotaRepository) IncrCounter(key string, expiresAt time.Time) (int64, error) {
+	var counter RateCounter
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "counter_key"}},
+			DoUpdates: clause.Assignments(map[string]any{"hits": gorm.Expr("hits + 1")}),
+		}).Create(&RateCounter{CounterKey: key, Hits: 1, ExpiresAt: expiresAt}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where(RateCounter{CounterKey: key}).First(&counter).Error
+	})
+	return counter.Hits, err
+}
+
+func (r *GormQuotaRepository) DecrCounter(key string) error {
+	return r.db.Model(&RateCounter{}).Where(RateCounter{CounterKey: key}).Where("hits > 0").
+		UpdateColumn("hits", gorm.Expr("hits - 1")).Error
+}
+
+func (r *GormQuotaRepository) GetCounter(key string) (int64, error) {
+	var hits []int64
+	if err := r.db.Model(&RateCounter{}).Where(RateCounter{CounterKey: key}).Pluck("hits", &hits).Error; err != nil {
+		return 0, err
+	}
+	if len(hits) == 0 {
+		return 0, nil
+	}
+	return hits[0], nil
+}
+
+func (r *GormQuotaRepository) DeleteExpiredCounters(now time.Time) error {
+	return r.db.Where("expires_at < ?", now).Delete(&RateCounter{}).Error
+}
+
+func (r *GormQuotaRepository) GetOverrides(uid string) ([]model.QuotaLimit, error) {
+	var overrides []QuotaOverride
+	tx := r.db.Where(QuotaOverride{UID: uid}).Order("model").Find(&overrides)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	result := []model.QuotaLimit{}
+	for _, item := range overrides {
+		result = append(result, model.QuotaLimit{
+			Model:             item.Model,
+			RequestsPerMinute: item.RequestsPerMinute,
+			TokensPerDay:      item.TokensPerDay,
+		})
+	}
+	return result, nil
+}
+
+func (r *GormQuotaRepository) SaveOverride(uid string, limit *model.QuotaLimit) error {
+	params := QuotaOverride{
+		UID:               uid,
+		Model:             limit.Model,
+		RequestsPerMinute: limit.RequestsPerMinute,
+		TokensPerDay:      limit.TokensPerDay,
+	}
+	return r.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&params).Error
+}
+
+func (r *GormQuotaRepository) DeleteOverride(uid, model string) error {
+	return r.db.Where("uid = ? AND model = ?", uid, model).Delete(&QuotaOverride{}).Error
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 消息全文索引的实现，启动时按数据库类型和能力检测
+const (
+	fullTextNone = ""
+	// fullTextFTS5Trigram SQLite 3.34以上的trigram分词，支持任意语言的子串匹配，关键词至少3个字符
+	fullTextFTS5Trigram = "fts5_trigram"
+	// fullTextFTS5 默认的unicode61分词按空白和标点切分，连续的中日韩文字被视为一个词
+	fullTextFTS5 = "fts5"
+	// fullTextMySQL 使用ngram解析器的FULLTEXT索引，关键词至少2个字符
+	fullTextMySQL = "mysql_ngram"
+
+	messagesFTSTable = "messages_fts"
+)
+
+var messagesFTSTriggers = []string{"messages_fts_insert", "messages_fts_delete", "messages_fts_update"}
+
+// setupFullText 创建全文索引，数据库不支持时返回fullTextNone，搜索退化为LIKE
+func setupFullText(db *gorm.DB) string {
+	switch db.Dialector.Name() {
+	case "sqlite":
+		return setupSQLiteFTS(db)
+	case "mysql":
+		return setupMySQLFullText(db)
+	}
+	return fullTextNone
+}
+
+// setupSQLiteFTS 创建以messages为外部内容的FTS5表，并用触发器保持同步。
+// mattn/go-sqlite3需要使用sqlite_fts5构建标签才包含FTS5
+func setupSQLiteFTS(db *gorm.DB) string {
+	var options []string
+	db.Raw("PRAGMA compile_options").Scan(&options)
+	if !slices.Contains(options, "ENABLE_FTS5") {
+		// 之前使用带FTS5的版本创建的触发器会导致写入消息失败
+		for _, trigger := range messagesFTSTriggers {
+			db.Exec("DROP TRIGGER IF EXISTS " + trigger)
+		}
+		log.Info("sqlite fts5 unavailable, fallback to LIKE search")
+		return fullTextNone
+	}
+
+	var existing string
+	db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", messagesFTSTable).Scan(&existing)
+	kind := fullTextFTS5
+	switch {
+	case strings.Contains(existing, "trigram"):
+		kind = fullTextFTS5Trigram
+	case existing == "":
+		// trigram分词需要SQLite 3.34以上
+		kind = fullTextFTS5Trigram
+		err := db.Exec("CREATE VIRTUAL TABLE " + messagesFTSTable + " USING fts5(content, content='messages', content_rowid='rowid', tokenize='trigram')").Error
+		if err != nil {
+			kind = fullTextFTS5
+			err = db.Exec("CREATE VIRTUAL TABLE " + messagesFTSTable + " USING fts5(content, content='messages', content_rowid='rowid')").Error
+		}
+		if err != nil {
+			log.Warn("create sqlite fts5 table failed, fallback to LIKE search", zap.Error(err))
+			return fullTextNone
+		}
+	}
+
+	var triggers int64
+	db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ?", messagesFTSTriggers).Scan(&triggers)
+	if int(triggers) == len(messagesFTSTriggers) {
+		return kind
+	}
+	statements := []string{
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, content) VALUES (new.rowid, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+			INSERT INTO messages_fts(rowid, content) VALUES (new.rowid, new.content);
+		END`,
+		// 为触发器创建之前的消息建立索引
+		"INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Warn("create sqlite fts5 index failed, fallback to LIKE search", zap.Error(err))
+			for _, trigger := range messagesFTSTriggers {
+				db.Exec("DROP TRIGGER IF EXISTS " + trigger)
+			}
+			return fullTextNone
+		}
+	}
+	return kind
+}
+
+func setupMySQLFullText(db *gorm.DB) string {
+	const index = "idx_messages_content_fulltext"
+	if db.Migrator().HasIndex(&Message{}, index) {
+		return fullTextMySQL
+	}
+	err := db.Exec("ALTER TABLE messages ADD FULLTEXT INDEX " + index + " (content) WITH PARSER ngram").Error
+	if err != nil {
+		log.Info("mysql fulltext index unavailable, fallback to LIKE search", zap.Error(err))
+		return fullTextNone
+	}
+	return fullTextMySQL
+}
+
+// fullTextUsable 判断全文索引能否处理这些关键词，不能时使用LIKE
+func (r *GormConversationRepository) fullTextUsable(terms []string) bool {
+	for _, term := range terms {
+		switch r.fullText {
+		case fullTextNone:
+			return false
+		case fullTextFTS5Trigram:
+			if utf8.RuneCountInString(term) < 3 {
+				return false
+			}
+		case fullTextFTS5:
+			for _, c := range term {
+				if unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+					return false
+				}
+			}
+		case fullTextMySQL:
+			if utf8.RuneCountInString(term) < 2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SearchMessages 返回用户会话中包含所有关键词的消息。使用全文索引时按相关度排列，否则按时间倒序
+func (r *GormConversationRepository) SearchMessages(uid string, terms []string, limit int) ([]model.ConversationMessage, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	tx := r.db.Model(&Message{}).
+		Select("messages.*, conversations.title AS title").
+		Joins("JOIN conversations ON conversations.id = messages.conversation_id AND conversations.deleted_at IS NULL").
+		Where("conversations.uid = ? AND messages.role IN ?", uid, []string{model.RoleUser, model.RoleAssistant})
+	switch {
+	case !r.fullTextUsable(terms):
+		for _, term := range terms {
+			tx = tx.Where("messages.content LIKE ? ESCAPE '!'", "%"+escapeLike(term)+"%")
+		}
+		tx = tx.Order("messages.created_at DESC")
+	case r.fullText == fullTextMySQL:
+		match := "MATCH (messages.content) AGAINST (? IN BOOLEAN MODE)"
+		query := booleanQuery(terms)
+		tx = tx.Where(match, query).Clauses(clause.OrderBy{
+			Expression: clause.Expr{SQL: match + " DESC", Vars: []any{query}, WithoutParentheses: true},
+		})
+	default:
+		tx = tx.Joins("JOIN "+messagesFTSTable+" ON "+messagesFTSTable+".rowid = messages.rowid").
+			Where(messagesFTSTable+" MATCH ?", ftsQuery(terms, r.fullText == fullTextFTS5)).
+			Order(messagesFTSTable + ".rank")
+	}
+	var messages []struct {
+		Message
+		Title string
+	}
+	if err := tx.Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	result := make([]model.ConversationMessage, 0, len(messages))
+	for _, item := range messages {
+		result = append(result, model.ConversationMessage{
+			ConversationID: item.ConversationID,
+			Title:          item.Title,
+			Message: model.Message{
+				ID:        item.ID,
+				Parent:    item.Parent,
+				Role:      item.Role,
+				Content:   item.Content,
+				CreatedAt: item.CreatedAt,
+			},
+		})
+	}
+	return result, nil
+}
+
+// SearchTitles 返回标题包含所有关键词的会话。单个用户的会话数量有限，直接使用LIKE
+func (r *GormConversationRepository) SearchTitles(uid string, terms []string, limit int) ([]model.ConversationMeta, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	tx := r.db.Where(Conversation{UID: uid})
+	for _, term := range terms {
+		tx = tx.Where("title LIKE ? ESCAPE '!'", "%"+escapeLike(term)+"%")
+	}
+	var conversations []Conversation
+	if err := tx.Order("updated_at DESC").Limit(limit).Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+	result := make([]model.ConversationMeta, 0, len(conversations))
+	for _, item := range conversations {
+		result = append(result, model.ConversationMeta{
+			ID:        item.ID,
+			Title:     item.Title,
+			Model:     item.Model,
+			CreatedAt: item.CreatedAt,
+			UpdatedAt: item.UpdatedAt,
+		})
+	}
+	return result, nil
+}
+
+// escapeLike 转义LIKE中的通配符。MySQL字符串中的反斜杠本身需要转义，这里使用!作为转义字符
+func escapeLike(term string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(term)
+}
+
+// ftsQuery 每个关键词作为短语，多个关键词之间为AND。unicode61分词时按前缀匹配，
+// 使gopher也能匹配gophers
+func ftsQuery(terms []string, prefix bool) string {
+	phrases := make([]string, len(terms))
+	for i, term := range terms {
+		phrases[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		if prefix {
+			phrases[i] += "*"
+		}
+	}
+	return strings.Join(phrases, " ")
+}
+
+// booleanQuery MySQL布尔模式下要求每个关键词作为短语出现
+func booleanQuery(terms []string) string {
+	phrases := make([]string, len(terms))
+	for i, term := range terms {
+		phrases[i] = `+"` + strings.ReplaceAll(term, `"`, " ") + `"`
+	}
+	return strings.Join(phrases, " ")
+}
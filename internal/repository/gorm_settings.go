@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserSettings 布尔项按"关闭"存储，使零值与默认设置一致
+type UserSettings struct {
+	UID              string `gorm:"primarykey;type:varchar(64)"`
+	DisableAutoTitle bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func NewGormSettingsRepository(db *gorm.DB) (SettingsRepo, error) {
+	err := db.AutoMigrate(&UserSettings{})
+	if err != nil {
+		return nil, err
+	}
+	return &GormSettingsRepository{db}, nil
+}
+
+type GormSettingsRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormSettingsRepository) SaveSettings(uid string, settings *model.Settings) error {
+	params := UserSettings{
+		UID:              uid,
+		DisableAutoTitle: !settings.AutoTitle,
+	}
+	return r.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&params).Error
+}
+
+func (r *GormSettingsRepository) GetSettings(uid string) (*model.Settings, error) {
+	var settings UserSettings
+	tx := r.db.Where(UserSettings{UID: uid}).First(&settings)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &model.Settings{
+		AutoTitle: !settings.DisableAutoTitle,
+	}, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"gorm.io/gorm"
+)
+
+// 会话和消息使用同一个删除时间，恢复时只恢复随会话一起删除的消息。
+// 移入和移出回收站不修改updated_at，恢复后的会话仍在原来的位置
+
+func (r *GormConversationRepository) DeleteConversations(uid string, ids []string) ([]string, error) {
+	var deleted []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Conversation{}).Where("uid = ? AND id IN ?", uid, ids).Pluck("id", &deleted).Error; err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		now := time.Now()
+		if err := tx.Model(&Message{}).Where("conversation_id IN ?", deleted).UpdateColumn("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Conversation{}).Where("id IN ?", deleted).UpdateColumn("deleted_at", now).Error
+	})
+	return deleted, err
+}
+
+func (r *GormConversationRepository) GetDeletedConversations(uid string, after *model.TrashCursor, limit int) ([]model.ConversationMeta, error) {
+	tx := r.db.Unscoped().Where(Conversation{UID: uid}).Where("deleted_at IS NOT NULL")
+	if after != nil {
+		tx = tx.Where("deleted_at < ? OR (deleted_at = ? AND id < ?)", after.DeletedAt, after.DeletedAt, after.ID)
+	}
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	var conversations []Conversation
+	if err := tx.Order("deleted_at DESC, id DESC").Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(conversations))
+	for i, item := range conversations {
+		ids[i] = item.ID
+	}
+	tags, err := r.getTags(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := []model.ConversationMeta{}
+	for _, item := range conversations {
+		result = append(result, model.ConversationMeta{
+			ID:            item.ID,
+			Title:         item.Title,
+			Model:         item.Model,
+			MaxTokens:     item.MaxTokens,
+			Temperature:   item.Temperature,
+			CurrentNodeID: item.CurrentNodeID,
+			CollectionID:  item.CollectionID,
+			Tags:          tags[item.ID],
+			Pinned:        item.Pinned,
+			Archived:      item.Archived,
+			CreatedAt:     item.CreatedAt,
+			UpdatedAt:     item.UpdatedAt,
+			DeletedAt:     item.DeletedAt.Time,
+		})
+	}
+	return result, nil
+}
+
+// deletedIDs 返回ids中属于用户且在回收站中的会话
+func deletedIDs(tx *gorm.DB, uid string, ids []string) ([]string, error) {
+	var result []string
+	err := tx.Unscoped().Model(&Conversation{}).
+		Where("uid = ? AND id IN ? AND deleted_at IS NOT NULL", uid, ids).
+		Pluck("id", &result).Error
+	return result, err
+}
+
+func (r *GormConversationRepository) RestoreConversations(uid string, ids []string) ([]string, error) {
+	var restored []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if restored, err = deletedIDs(tx, uid, ids); err != nil || len(restored) == 0 {
+			return err
+		}
+		err = tx.Unscoped().Model(&Message{}).
+			Where("conversation_id IN ? AND deleted_at = (SELECT deleted_at FROM conversations WHERE conversations.id = messages.conversation_id)", restored).
+			UpdateColumn("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&Conversation{}).Where("id IN ?", restored).UpdateColumn("deleted_at", nil).Error
+	})
+	return restored, err
+}
+
+func (r *GormConversationRepository) PurgeConversations(uid string, ids []string) ([]string, error) {
+	var purged []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if purged, err = deletedIDs(tx, uid, ids); err != nil || len(purged) == 0 {
+			return err
+		}
+		if err := tx.Unscoped().Where("conversation_id IN ?", purged).Delete(&Message{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("conversation_id IN ?", purged).Delete(&ConversationTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ?", purged).Delete(&Conversation{}).Error
+	})
+	return purged, err
+}
+
+func (r *GormConversationRepository) GetDeletedBefore(before time.Time, limit int) (map[string][]string, error) {
+	var conversations []Conversation
+	tx := r.db.Unscoped().Select("id", "uid").Where("deleted_at < ?", before).Limit(limit).Find(&conversations)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	result := map[string][]string{}
+	for _, item := range conversations {
+		result[item.UID] = append(result[item.UID], item.ID)
+	}
+	return result, nil
+}
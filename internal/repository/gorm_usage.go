@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"gorm.io/gorm"
+)
+
+// Usage 用量明细，Day和Month按UTC存储，便于在SQLite和MySQL上用同样的语句汇总
+type Usage struct {
+	ID               uint   `gorm:"primarykey"`
+	UID              string `gorm:"index:idx_usage_uid_day;type:varchar(64)"`
+	Day              string `gorm:"index:idx_usage_uid_day;type:char(10)"`
+	Month            string `gorm:"type:char(7)"`
+	ConversationID   string `gorm:"type:char(36)"`
+	MessageID        string `gorm:"type:char(36)"`
+	Model            string `gorm:"type:varchar(64)"`
+	Upstream         string `gorm:"type:varchar(128)"`
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	CreatedAt        time.Time
+}
+
+func NewGormUsageRepository(db *gorm.DB) (UsageRepo, error) {
+	err := db.AutoMigrate(&Usage{})
+	if err != nil {
+		return nil, err
+	}
+	return &GormUsageRepository{db}, nil
+}
+
+type GormUsageRepository struct {
+	db *gorm.DB
+}
+
+func (r *GormUsageRepository) CreateUsage(uid string, record *model.UsageRecord) error {
+	createdAt := record.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	params := Usage{
+		UID:              uid,
+		Day:              createdAt.UTC().Format(time.DateOnly),
+		Month:            createdAt.UTC().Format("2006-01"),
+		ConversationID:   record.ConversationID,
+		MessageID:        record.MessageID,
+		Model:            record.Model,
+		Upstream:         record.Upstream,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+		Cost:             record.Cost,
+		CreatedAt:        createdAt,
+	}
+	return r.db.Create(&params).Error
+}
+
+func (r *GormUsageRepository) GetUsageStats(uid, period string, from, to time.Time) ([]model.UsageStat, error) {
+	column := "day"
+	if period == model.UsagePeriodMonthly {
+		column = "month"
+	}
+	var stats []model.UsageStat
+	tx := r.db.Model(&Usage{}).
+		Select(column+" AS period, model, COUNT(*) AS requests, "+
+			"SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, "+
+			"SUM(prompt_tokens + completion_tokens) AS total_tokens, SUM(cost) AS cost").
+		Where("uid = ? AND day >= ? AND day < ?", uid, from.UTC().Format(time.DateOnly), to.UTC().Format(time.DateOnly)).
+		Group(column + ", model").
+		Order(column + ", model").
+		Scan(&stats)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if stats == nil {
+		stats = []model.UsageStat{}
+	}
+	return stats, nil
+}
+
+func (r *GormUsageRepository) SumTokens(uid, model string, day time.Time) (int64, error) {
+	var total int64
+	tx := r.db.Model(&Usage{}).
+		Select("COALESCE(SUM(prompt_tokens + completion_tokens), 0)").
+		Where(Usage{UID: uid, Day: day.UTC().Format(time.DateOnly), Model: model}).
+		Scan(&total)
+	return total, tx.Error
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// vectorCacheSize 内存中缓存的namespace数
+	vectorCacheSize = 64
+	// vectorCacheTTL 多实例部署时其他实例写入的向量最迟在该时间后可见
+	vectorCacheTTL = 5 * time.Minute
+)
+
+type Vector struct {
+	Namespace string `gorm:"primarykey;type:varchar(128)"`
+	ID        string `gorm:"primarykey;type:varchar(64)"`
+	Ref       string `gorm:"index;type:varchar(64)"`
+	Dim       int
+	// Data 小端序的float32数组
+	Data      []byte
+	CreatedAt time.Time
+}
+
+// vectorEntry 缓存中归一化后的向量
+type vectorEntry struct {
+	id     string
+	ref    string
+	values []float32
+}
+
+// GormVectorIndex 向量保存在数据库中，检索时把整个namespace加载到内存后暴力计算，
+// 适合单个知识库或用户在十万条以内的规模，不依赖额外的服务
+type GormVectorIndex struct {
+	db    *gorm.DB
+	cache *expirable.LRU[string, []vectorEntry]
+}
+
+func NewGormVectorIndex(db *gorm.DB) (VectorIndex, error) {
+	err := db.AutoMigrate(&Vector{})
+	if err != nil {
+		return nil, err
+	}
+	return &GormVectorIndex{
+		db:    db,
+		cache: expirable.NewLRU[string, []vectorEntry](vectorCacheSize, nil, vectorCacheTTL),
+	}, nil
+}
+
+func (r *GormVectorIndex) Upsert(namespace string, vectors []model.Vector) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+	params := make([]Vector, 0, len(vectors))
+	for _, item := range vectors {
+		params = append(params, Vector{
+			Namespace: namespace,
+			ID:        item.ID,
+			Ref:       item.Ref,
+			Dim:       len(item.Values),
+			Data:      encodeVector(item.Values),
+		})
+	}
+	err := r.db.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).CreateInBatches(params, 100).Error
+	r.cache.Remove(namespace)
+	return err
+}
+
+func (r *GormVectorIndex) DeleteByRef(namespace, ref string) error {
+	err := r.db.Where(Vector{Namespace: namespace, Ref: ref}).Delete(&Vector{}).Error
+	r.cache.Remove(namespace)
+	return err
+}
+
+func (r *GormVectorIndex) DeleteNamespace(namespace string) error {
+	err := r.db.Where(Vector{Namespace: namespace}).Delete(&Vector{}).Error
+	r.cache.Remove(namespace)
+	return err
+}
+
+func (r *GormVectorIndex) Search(namespace string, query []float32, k int) ([]model.VectorMatch, error) {
+	entries, err := r.load(namespace)
+	if err != nil {
+		return nil, err
+	}
+	query = normalize(query)
+	matches := make([]model.VectorMatch, 0, len(entries))
+	for _, entry := range entries {
+		// 维度不同的向量来自其他模型，无法比较
+		if len(entry.values) != len(query) {
+			continue
+		}
+		var dot float64
+		for i, v := range entry.values {
+			dot += float64(v) * float64(query[i])
+		}
+		matches = append(matches, model.VectorMatch{ID: entry.id, Ref: entry.ref, Score: dot})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+func (r *GormVectorIndex) load(namespace string) ([]vectorEntry, error) {
+	if entries, ok := r.cache.Get(namespace); ok {
+		return entries, nil
+	}
+	var vectors []Vector
+	tx := r.db.Where(Vector{Namespace: namespace}).Find(&vectors)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	entries := make([]vectorEntry, 0, len(vectors))
+	for _, item := range vectors {
+		entries = append(entries, vectorEntry{
+			id:     item.ID,
+			ref:    item.Ref,
+			values: normalize(decodeVector(item.Data)),
+		})
+	}
+	r.cache.Add(namespace, entries)
+	return entries, nil
+}
+
+func encodeVector(values []float32) []byte {
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	return data
+}
+
+func decodeVector(data []byte) []float32 {
+	values := make([]float32, len(data)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return values
+}
+
+// normalize 返回单位向量，之后点积即为余弦相似度
+func normalize(values []float32) []float32 {
+	var norm float64
+	for _, v := range values {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return values
+	}
+	norm = math.Sqrt(norm)
+	result := make([]float32, len(values))
+	for i, v := range values {
+		result[i] = float32(float64(v) / norm)
+	}
+	return result
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "github.com/coxlong/eureka/internal/model"
+
+type KeysRepo interface {
+	SaveKey(uid string, key *model.ProviderKey) error
+	GetKey(uid, provider string) (*model.ProviderKey, error)
+	GetKeys(uid string) ([]model.ProviderKey, error)
+	DeleteKey(uid, provider string) error
+}
@@ -0,0 +1,21 @@
+package repository
+
+import "github.com/coxlong/eureka/internal/model"
+
+type KnowledgeRepo interface {
+	CreateCollection(uid string, collection *model.Collection) error
+	GetCollection(uid, id string) (*model.Collection, error)
+	GetCollections(uid string) ([]model.Collection, error)
+	// DeleteCollection 删除知识库及其中的文档和分块
+	DeleteCollection(uid, id string) error
+	CreateDocument(uid string, document *model.Document) error
+	// UpdateDocument 更新文档的处理状态、错误和分块数
+	UpdateDocument(document *model.Document) error
+	GetDocument(uid, id string) (*model.Document, error)
+	GetDocuments(uid, collectionID string) ([]model.Document, error)
+	// DeleteDocument 删除文档及其分块
+	DeleteDocument(uid, id string) error
+	CreateChunks(chunks []model.Chunk) error
+	// GetChunks 按ID返回分块和所属文档的名称，不存在的ID被忽略
+	GetChunks(collectionID string, ids []string) ([]model.RetrievedChunk, error)
+}
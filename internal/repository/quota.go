@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+)
+
+// QuotaRepo 限额的计数和用户覆盖配置，多个副本通过共享的存储计数
+type QuotaRepo interface {
+	// IncrCounter 把key的计数加一并返回加一后的值，expiresAt之后计数可以被清理
+	IncrCounter(key string, expiresAt time.Time) (int64, error)
+	// DecrCounter 撤销一次IncrCounter，用于被拒绝的请求
+	DecrCounter(key string) error
+	// GetCounter 返回key当前的计数，不存在时返回0
+	GetCounter(key string) (int64, error)
+	DeleteExpiredCounters(now time.Time) error
+	GetOverrides(uid string) ([]model.QuotaLimit, error)
+	SaveOverride(uid string, limit *model.QuotaLimit) error
+	DeleteOverride(uid, model string) error
+}
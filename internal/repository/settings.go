@@ -0,0 +1,8 @@
+package repository
+
+import "github.com/coxlong/eureka/internal/model"
+
+type SettingsRepo interface {
+	SaveSettings(uid string, settings *model.Settings) error
+	GetSettings(uid string) (*model.Settings, error)
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+)
+
+type UsageRepo interface {
+	CreateUsage(uid string, record *model.UsageRecord) error
+	// GetUsageStats 按period汇总[from, to)之间的用量
+	GetUsageStats(uid, period string, from, to time.Time) ([]model.UsageStat, error)
+	// SumTokens 返回用户某天(UTC)使用的token总数，model为空时统计所有模型
+	SumTokens(uid, model string, day time.Time) (int64, error)
+}
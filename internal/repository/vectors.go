@@ -0,0 +1,14 @@
+package repository
+
+import "github.com/coxlong/eureka/internal/model"
+
+// VectorIndex 向量索引，向量按namespace隔离，如一个知识库或一个用户的消息
+type VectorIndex interface {
+	// Upsert 写入或覆盖向量
+	Upsert(namespace string, vectors []model.Vector) error
+	// DeleteByRef 删除namespace中属于ref的所有向量
+	DeleteByRef(namespace, ref string) error
+	DeleteNamespace(namespace string) error
+	// Search 返回与query余弦相似度最高的k个向量，按相似度降序排列
+	Search(namespace string, query []float32, k int) ([]model.VectorMatch, error)
+}
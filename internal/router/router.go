@@ -23,7 +23,10 @@ func Setup(env *config.Env, store sessions.Store, handlerManager *handler.Manage
 	// 允许跨域
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{env.FrontendAddr}
-	config.AllowHeaders = append(config.AllowHeaders, "Authorization")
+	config.AllowHeaders = append(config.AllowHeaders, "Authorization", "Last-Event-ID")
+	config.ExposeHeaders = append(config.ExposeHeaders, "X-Answer-ID", "X-Prompt-Tokens", "X-Next-Cursor",
+		"X-RateLimit-Limit-Requests", "X-RateLimit-Remaining-Requests", "X-RateLimit-Reset-Requests",
+		"X-RateLimit-Limit-Tokens", "X-RateLimit-Remaining-Tokens", "X-RateLimit-Reset-Tokens")
 	config.AllowCredentials = true
 
 	engine.Use(cors.New(config))
@@ -38,10 +41,41 @@ func Setup(env *config.Env, store sessions.Store, handlerManager *handler.Manage
 
 	// 注册/chat/completions接口
 	router.POST("/chat/completions", handlerManager.Chat.Completions)
+	router.POST("/chat/completions/:answerID/cancel", handlerManager.Chat.Cancel)
+	router.GET("/chat/streams/:answerID", handlerManager.Chat.ResumeStream)
+	router.GET("/chat/ws", handlerManager.Chat.WebSocket)
+
+	// 注册/embeddings接口
+	router.POST("/embeddings", handlerManager.Embeddings.Create)
+
+	// 注册/models接口
+	router.GET("/models", handlerManager.Models.GetModels)
+
+	// 注册/tools接口
+	router.GET("/tools", handlerManager.Tools.GetTools)
 
 	// 注册conversations接口
 	setupConversationsRouter(router.Group("/conversations"), handlerManager.Conversations)
 
+	// 注册keys接口
+	setupKeysRouter(router.Group("/keys"), handlerManager.Keys)
+
+	// 注册attachments接口
+	setupAttachmentsRouter(router.Group("/attachments"), handlerManager.Attachments)
+
+	// 注册collections接口
+	setupCollectionsRouter(router.Group("/collections"), handlerManager.Knowledge)
+
+	// 注册settings接口
+	router.GET("/settings", handlerManager.Settings.GetSettings)
+	router.PUT("/settings", handlerManager.Settings.SaveSettings)
+
+	// 注册usage接口
+	router.GET("/usage", handlerManager.Usage.GetUsage)
+
+	// 注册quotas接口
+	setupQuotasRouter(router.Group("/quotas"), handlerManager.Quota)
+
 	return engine, nil
 }
 
@@ -52,7 +86,44 @@ func setupAuthRouter(router *gin.RouterGroup, handle handler.AuthHandler) {
 }
 
 func setupConversationsRouter(router *gin.RouterGroup, handle handler.ConversationsHandler) {
+	router.GET("/search", handle.Search)
+	router.GET("/trash", handle.GetTrash)
+	router.POST("/trash/:id/restore", handle.RestoreConversation)
+	router.DELETE("/trash/:id", handle.PurgeConversation)
+	router.POST("/delete", handle.DeleteConversations)
 	router.GET("/:id", handle.GetConversation)
 	router.GET("/", handle.GetConversations)
 	router.PUT("/:id", handle.UpdateTitle)
+	router.PUT("/:id/collection", handle.UpdateCollection)
+	router.PUT("/:id/tags", handle.UpdateTags)
+	router.PUT("/:id/pinned", handle.UpdatePinned)
+	router.PUT("/:id/archived", handle.UpdateArchived)
+	router.DELETE("/:id", handle.DeleteConversation)
+}
+
+func setupKeysRouter(router *gin.RouterGroup, handle handler.KeysHandler) {
+	router.GET("/", handle.GetKeys)
+	router.PUT("/:provider", handle.SaveKey)
+	router.DELETE("/:provider", handle.DeleteKey)
+}
+
+func setupQuotasRouter(router *gin.RouterGroup, handle handler.QuotaHandler) {
+	router.GET("/", handle.GetQuotas)
+	router.PUT("/", handle.SaveQuota)
+	router.DELETE("/", handle.DeleteQuota)
+}
+
+func setupCollectionsRouter(router *gin.RouterGroup, handle handler.KnowledgeHandler) {
+	router.POST("/", handle.CreateCollection)
+	router.GET("/", handle.GetCollections)
+	router.DELETE("/:id", handle.DeleteCollection)
+	router.GET("/:id/documents", handle.GetDocuments)
+	router.POST("/:id/documents", handle.UploadDocument)
+	router.DELETE("/:id/documents/:documentID", handle.DeleteDocument)
+}
+
+func setupAttachmentsRouter(router *gin.RouterGroup, handle handler.AttachmentsHandler) {
+	router.POST("/", handle.Upload)
+	router.GET("/:id", handle.GetAttachment)
+	router.DELETE("/:id", handle.DeleteAttachment)
 }
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/blob"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/repository"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAttachmentMaxBytes = 20 << 20
+	defaultSignedURLExpiry    = time.Hour
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	ErrUnsupportedImage   = errors.New("unsupported image type, expected png, jpeg, gif or webp")
+)
+
+// imageTypes 上游视觉模型普遍支持的图片格式
+var imageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+type AttachmentsService interface {
+	// Upload 保存图片，格式根据内容判断，与文件名和请求头无关
+	Upload(ctx context.Context, uid, name string, r io.Reader) (*model.Attachment, error)
+	// Open 返回用户的图片内容，调用方负责关闭
+	Open(ctx context.Context, uid, id string) (*model.Attachment, io.ReadCloser, error)
+	Delete(ctx context.Context, uid, id string) error
+	// ImageURL 返回发给上游的图片地址：开启SignedURL且存储支持时为临时链接，否则为data URL
+	ImageURL(ctx context.Context, uid, id string) (string, error)
+}
+
+func NewAttachmentsService(r repository.AttachmentsRepo, store blob.Store, cfg *config.Attachments) AttachmentsService {
+	s := &DefaultAttachmentsService{
+		repo:            r,
+		store:           store,
+		maxBytes:        cfg.MaxBytes,
+		signedURL:       cfg.SignedURL,
+		signedURLExpiry: cfg.SignedURLExpiry,
+	}
+	if s.maxBytes <= 0 {
+		s.maxBytes = defaultAttachmentMaxBytes
+	}
+	if s.signedURLExpiry <= 0 {
+		s.signedURLExpiry = defaultSignedURLExpiry
+	}
+	return s
+}
+
+type DefaultAttachmentsService struct {
+	repo            repository.AttachmentsRepo
+	store           blob.Store
+	maxBytes        int
+	signedURL       bool
+	signedURLExpiry time.Duration
+}
+
+func (s *DefaultAttachmentsService) Upload(ctx context.Context, uid, name string, r io.Reader) (*model.Attachment, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(s.maxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > s.maxBytes {
+		return nil, ErrAttachmentTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if !slices.Contains(imageTypes, contentType) {
+		return nil, ErrUnsupportedImage
+	}
+	attachment := &model.Attachment{
+		ID:          uuid.NewString(),
+		Name:        name,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	key := uid + "/" + attachment.ID
+	if err := s.store.Put(ctx, key, data, contentType); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateAttachment(uid, key, attachment); err != nil {
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Warn("delete orphan attachment failed", zap.String("key", key), zap.Error(err))
+		}
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (s *DefaultAttachmentsService) get(uid, id string) (*model.Attachment, string, error) {
+	attachment, key, err := s.repo.GetAttachment(uid, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", ErrAttachmentNotFound
+	}
+	return attachment, key, err
+}
+
+func (s *DefaultAttachmentsService) Open(ctx context.Context, uid, id string) (*model.Attachment, io.ReadCloser, error) {
+	attachment, key, err := s.get(uid, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := s.store.Get(ctx, key)
+	if errors.Is(err, blob.ErrNotFound) {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, reader, nil
+}
+
+func (s *DefaultAttachmentsService) Delete(ctx context.Context, uid, id string) error {
+	_, key, err := s.get(uid, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteAttachment(uid, id); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, key)
+}
+
+func (s *DefaultAttachmentsService) ImageURL(ctx context.Context, uid, id string) (string, error) {
+	if presigner, ok := s.store.(blob.Presigner); ok && s.signedURL {
+		_, key, err := s.get(uid, id)
+		if err != nil {
+			return "", err
+		}
+		return presigner.PresignGet(key, s.signedURLExpiry)
+	}
+	attachment, reader, err := s.Open(ctx, uid, id)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + attachment.ContentType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
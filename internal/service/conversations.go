@@ -1,24 +1,76 @@
 package service
 
 import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
 	"github.com/coxlong/eureka/internal/repository"
 )
 
+var (
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrInvalidMessageTree = errors.New("invalid message tree")
+	ErrTagTooLong         = errors.New("tag too long")
+	ErrTooManyTags        = errors.New("too many tags")
+)
+
+const (
+	// maxTags 一个会话最多的标签数
+	maxTags = 20
+	// maxTagLength 标签的最大字符数
+	maxTagLength = 64
+)
+
 type ConversationsService interface {
 	CreateConversation(uid string, meta *model.ConversationMeta, messages []model.Message) error
 	UpdateConversation(uid string, meta *model.ConversationMeta, messages []model.Message) error
 	GetConversation(cid string, uid string) (*model.ConversationMeta, []model.Message, error)
-	GetConversations(uid string) ([]model.ConversationMeta, error)
+	// GetConversations 返回一页会话，置顶的会话在前，其余按更新时间降序排列，同时返回下一页的cursor，没有下一页时为空。
+	// filter.Limit为0时返回全部
+	GetConversations(uid string, filter model.ConversationFilter) ([]model.ConversationMeta, string, error)
+	GetMessageChain(cid, uid, nodeID string) ([]model.Message, error)
+	GetMessageChainWithSummary(cid, uid, nodeID string) ([]model.Message, *model.Message, error)
+	SaveSummary(uid, cid string, summary *model.Message) error
 	UpdateTitle(uid, cid, title string) error
+	// SetCollection 修改会话关联的知识库，collectionID为空时取消关联
+	SetCollection(uid, cid, collectionID string) error
+	SetPinned(uid, cid string, pinned bool) error
+	SetArchived(uid, cid string, archived bool) error
+	// SetTags 替换会话的标签，返回去除空白和重复后的标签
+	SetTags(uid, cid string, tags []string) ([]string, error)
+	// DeleteConversations 把会话移入回收站，返回实际删除的会话ID
+	DeleteConversations(uid string, ids []string) ([]string, error)
+	// GetTrash 与GetConversations一样分页返回回收站中的会话，按删除时间降序排列，limit为0时返回全部
+	GetTrash(uid string, after *model.TrashCursor, limit int) ([]model.ConversationMeta, string, error)
+	// RestoreConversations 从回收站恢复会话，返回实际恢复的会话ID
+	RestoreConversations(uid string, ids []string) ([]string, error)
+	// PurgeConversations 永久删除回收站中的会话以及消息的向量，返回实际删除的会话ID
+	PurgeConversations(uid string, ids []string) ([]string, error)
+	// PurgeExpired 永久删除在回收站中超过保留天数的会话，返回删除的数量
+	PurgeExpired(now time.Time) (int, error)
 }
 
-func NewConversationService(r repository.ConversationsRepo) ConversationsService {
-	return &DefaultConversationService{r}
+func NewConversationService(r repository.ConversationsRepo, index repository.VectorIndex, cfg *config.Trash) ConversationsService {
+	s := &DefaultConversationService{
+		repo:          r,
+		index:         index,
+		retentionDays: cfg.RetentionDays,
+	}
+	if s.retentionDays == 0 {
+		s.retentionDays = defaultTrashRetentionDays
+	}
+	return s
 }
 
 type DefaultConversationService struct {
-	repo repository.ConversationsRepo
+	repo  repository.ConversationsRepo
+	index repository.VectorIndex
+	// retentionDays 小于0时回收站中的会话不会自动删除
+	retentionDays int
 }
 
 func (s *DefaultConversationService) CreateConversation(uid string, meta *model.ConversationMeta, messages []model.Message) error {
@@ -46,11 +98,107 @@ func (s *DefaultConversationService) UpdateConversation(uid string, meta *model.
 }
 
 func (s *DefaultConversationService) GetConversation(cid string, uid string) (*model.ConversationMeta, []model.Message, error) {
-	return s.repo.GetConversationByID(cid, uid)
+	meta, messages, err := s.repo.GetConversationByID(cid, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 摘要节点只在服务端使用，不作为分支展示给客户端
+	result := []model.Message{}
+	for _, item := range messages {
+		if item.Role != model.RoleSummary {
+			result = append(result, item)
+		}
+	}
+	return meta, result, nil
+}
+
+func (s *DefaultConversationService) GetConversations(uid string, filter model.ConversationFilter) ([]model.ConversationMeta, string, error) {
+	limit := filter.Limit
+	if limit > 0 {
+		// 多取一个判断是否还有下一页
+		filter.Limit = limit + 1
+	}
+	conversations, err := s.repo.GetConversations(uid, &filter)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 || len(conversations) <= limit {
+		return conversations, "", nil
+	}
+	conversations = conversations[:limit]
+	last := conversations[limit-1]
+	return conversations, model.ConversationCursor{Pinned: last.Pinned, UpdatedAt: last.UpdatedAt, ID: last.ID}.String(), nil
+}
+
+// GetMessageChain 返回从根节点到nodeID的消息链，nodeID为空时返回空链
+func (s *DefaultConversationService) GetMessageChain(cid, uid, nodeID string) ([]model.Message, error) {
+	_, messages, err := s.repo.GetConversationByID(cid, uid)
+	if err != nil {
+		return nil, err
+	}
+	return buildMessageChain(messages, nodeID)
+}
+
+// GetMessageChainWithSummary 返回消息链以及链上最新的摘要。摘要只有在它覆盖的
+// 最后一条消息位于当前链上时才有效，用户在其上方编辑或新建分支后自然失效
+func (s *DefaultConversationService) GetMessageChainWithSummary(cid, uid, nodeID string) ([]model.Message, *model.Message, error) {
+	_, messages, err := s.repo.GetConversationByID(cid, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, err := buildMessageChain(messages, nodeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	depth := map[string]int{}
+	for i, item := range chain {
+		depth[item.ID] = i
+	}
+	var summary *model.Message
+	for i, item := range messages {
+		if item.Role != model.RoleSummary {
+			continue
+		}
+		d, ok := depth[item.Parent]
+		// 消息按创建时间排序，同一节点上的多个摘要取最新的
+		if ok && (summary == nil || d >= depth[summary.Parent]) {
+			summary = &messages[i]
+		}
+	}
+	return chain, summary, nil
 }
 
-func (s *DefaultConversationService) GetConversations(uid string) ([]model.ConversationMeta, error) {
-	return s.repo.GetConversations(uid)
+// SaveSummary 保存摘要节点，摘要不改变会话的当前节点
+func (s *DefaultConversationService) SaveSummary(uid, cid string, summary *model.Message) error {
+	if _, _, err := s.repo.GetConversationByID(cid, uid); err != nil {
+		return err
+	}
+	summary.Role = model.RoleSummary
+	return s.repo.CreateMessages(cid, []model.Message{*summary})
+}
+
+func buildMessageChain(messages []model.Message, nodeID string) ([]model.Message, error) {
+	messagesMap := map[string]model.Message{}
+	for _, item := range messages {
+		messagesMap[item.ID] = item
+	}
+	chain := []model.Message{}
+	for nodeID != "" {
+		// 防止消息树中出现环
+		if len(chain) > len(messages) {
+			return nil, ErrInvalidMessageTree
+		}
+		message, ok := messagesMap[nodeID]
+		if !ok || message.Role == model.RoleSummary {
+			return nil, ErrMessageNotFound
+		}
+		chain = append(chain, message)
+		nodeID = message.Parent
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
 }
 
 func (s *DefaultConversationService) UpdateTitle(uid, cid, title string) error {
@@ -59,3 +207,38 @@ func (s *DefaultConversationService) UpdateTitle(uid, cid, title string) error {
 		Title: title,
 	})
 }
+
+func (s *DefaultConversationService) SetCollection(uid, cid, collectionID string) error {
+	return s.repo.UpdateCollection(uid, cid, collectionID)
+}
+
+func (s *DefaultConversationService) SetPinned(uid, cid string, pinned bool) error {
+	return s.repo.UpdatePinned(uid, cid, pinned)
+}
+
+func (s *DefaultConversationService) SetArchived(uid, cid string, archived bool) error {
+	return s.repo.UpdateArchived(uid, cid, archived)
+}
+
+func (s *DefaultConversationService) SetTags(uid, cid string, tags []string) ([]string, error) {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, ErrTagTooLong
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	if len(result) > maxTags {
+		return nil, ErrTooManyTags
+	}
+	if err := s.repo.SetTags(uid, cid, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/crypto"
+	"github.com/coxlong/eureka/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrKeyNotFound   = errors.New("key not found")
+	ErrVaultDisabled = errors.New("key vault is disabled, set Vault.Secret to enable it")
+)
+
+type KeysService interface {
+	SaveKey(uid, provider, key string) error
+	// GetKey 返回解密后的密钥，不存在时返回ErrKeyNotFound
+	GetKey(uid, provider string) (string, error)
+	GetKeys(uid string) ([]model.ProviderKey, error)
+	DeleteKey(uid, provider string) error
+}
+
+// NewKeysService cipher为nil时不启用密钥库，不能保存密钥，查找时总是返回ErrKeyNotFound
+func NewKeysService(r repository.KeysRepo, cipher *crypto.Cipher) KeysService {
+	return &DefaultKeysService{r, cipher}
+}
+
+type DefaultKeysService struct {
+	repo   repository.KeysRepo
+	cipher *crypto.Cipher
+}
+
+func (s *DefaultKeysService) SaveKey(uid, provider, key string) error {
+	if s.cipher == nil {
+		return ErrVaultDisabled
+	}
+	encrypted, err := s.cipher.Encrypt(key)
+	if err != nil {
+		return err
+	}
+	return s.repo.SaveKey(uid, &model.ProviderKey{
+		Provider: provider,
+		Key:      encrypted,
+		Hint:     keyHint(key),
+	})
+}
+
+func (s *DefaultKeysService) GetKey(uid, provider string) (string, error) {
+	if s.cipher == nil {
+		return "", ErrKeyNotFound
+	}
+	key, err := s.repo.GetKey(uid, provider)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.cipher.Decrypt(key.Key)
+}
+
+func (s *DefaultKeysService) GetKeys(uid string) ([]model.ProviderKey, error) {
+	return s.repo.GetKeys(uid)
+}
+
+func (s *DefaultKeysService) DeleteKey(uid, provider string) error {
+	return s.repo.DeleteKey(uid, provider)
+}
+
+// keyHint 只保留密钥首尾少量字符用于展示
+func keyHint(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:3] + "..." + key[len(key)-4:]
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/coxlong/eureka/internal/pkg/document"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/pkg/tokenizer"
+	"github.com/coxlong/eureka/internal/repository"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDocumentMaxBytes = 10 << 20
+	defaultChunkTokens      = 500
+	defaultChunkOverlap     = 50
+	defaultRetrieveTopK     = 4
+	defaultRetrieveMinScore = 0.3
+	// embedBatchSize 每次请求上游计算向量的分块数
+	embedBatchSize = 64
+)
+
+var (
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrDocumentNotFound   = errors.New("document not found")
+	ErrDocumentTooLarge   = errors.New("document too large")
+)
+
+// EmbedFunc 计算一组文本的向量，返回的向量与输入一一对应
+type EmbedFunc func(ctx context.Context, inputs []string) ([][]float32, error)
+
+type KnowledgeService interface {
+	CreateCollection(uid string, collection *model.Collection) error
+	GetCollection(uid, id string) (*model.Collection, error)
+	GetCollections(uid string) ([]model.Collection, error)
+	DeleteCollection(uid, id string) error
+	GetDocuments(uid, collectionID string) ([]model.Document, error)
+	// AddDocument 提取文本并分块，保存状态为processing的文档，返回文档和分块的内容。
+	// 分块之后需要调用IndexDocument计算向量
+	AddDocument(uid, collectionID, name string, r io.Reader) (*model.Document, []string, error)
+	// IndexDocument 计算分块的向量并保存，完成后文档状态变为ready，失败时为failed
+	IndexDocument(ctx context.Context, document *model.Document, chunks []string, embed EmbedFunc) error
+	DeleteDocument(uid, id string) error
+	// Retrieve 返回知识库中与query最相似的分块，相似度低于MinScore的分块被忽略
+	Retrieve(uid, collectionID string, query []float32) ([]model.RetrievedChunk, error)
+}
+
+func NewKnowledgeService(r repository.KnowledgeRepo, index repository.VectorIndex, cfg *config.Knowledge) KnowledgeService {
+	s := &DefaultKnowledgeService{
+		repo:         r,
+		index:        index,
+		maxBytes:     cfg.MaxBytes,
+		chunkTokens:  cfg.ChunkTokens,
+		chunkOverlap: cfg.ChunkOverlap,
+		topK:         cfg.TopK,
+		minScore:     cfg.MinScore,
+	}
+	if s.maxBytes <= 0 {
+		s.maxBytes = defaultDocumentMaxBytes
+	}
+	if s.chunkTokens <= 0 {
+		s.chunkTokens = defaultChunkTokens
+	}
+	if s.chunkOverlap < 0 || s.chunkOverlap >= s.chunkTokens {
+		s.chunkOverlap = min(defaultChunkOverlap, s.chunkTokens/10)
+	}
+	if s.topK <= 0 {
+		s.topK = defaultRetrieveTopK
+	}
+	if s.minScore == 0 {
+		s.minScore = defaultRetrieveMinScore
+	}
+	return s
+}
+
+type DefaultKnowledgeService struct {
+	repo         repository.KnowledgeRepo
+	index        repository.VectorIndex
+	maxBytes     int
+	chunkTokens  int
+	chunkOverlap int
+	topK         int
+	minScore     float64
+}
+
+// collectionNamespace 知识库在向量索引中的namespace
+func collectionNamespace(id string) string {
+	return "collection:" + id
+}
+
+func (s *DefaultKnowledgeService) CreateCollection(uid string, collection *model.Collection) error {
+	collection.ID = uuid.NewString()
+	return s.repo.CreateCollection(uid, collection)
+}
+
+func (s *DefaultKnowledgeService) GetCollection(uid, id string) (*model.Collection, error) {
+	collection, err := s.repo.GetCollection(uid, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCollectionNotFound
+	}
+	return collection, err
+}
+
+func (s *DefaultKnowledgeService) GetCollections(uid string) ([]model.Collection, error) {
+	return s.repo.GetCollections(uid)
+}
+
+func (s *DefaultKnowledgeService) DeleteCollection(uid, id string) error {
+	err := s.repo.DeleteCollection(uid, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCollectionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.index.DeleteNamespace(collectionNamespace(id))
+}
+
+func (s *DefaultKnowledgeService) GetDocuments(uid, collectionID string) ([]model.Document, error) {
+	if _, err := s.GetCollection(uid, collectionID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDocuments(uid, collectionID)
+}
+
+func (s *DefaultKnowledgeService) AddDocument(uid, collectionID, name string, r io.Reader) (*model.Document, []string, error) {
+	if _, err := s.GetCollection(uid, collectionID); err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, int64(s.maxBytes)+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > s.maxBytes {
+		return nil, nil, ErrDocumentTooLarge
+	}
+	contentType, err := document.DetectType(name, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	text, err := document.Extract(contentType, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 分块按cl100k_base计数，与多数embedding模型的上下文长度单位接近
+	tok, err := tokenizer.ForModel("", tokenizer.EncodingCL100K)
+	if err != nil {
+		return nil, nil, err
+	}
+	chunks := document.Chunk(tok, text, s.chunkTokens, s.chunkOverlap)
+	if len(chunks) == 0 {
+		return nil, nil, document.ErrNoText
+	}
+	doc := &model.Document{
+		ID:           uuid.NewString(),
+		CollectionID: collectionID,
+		Name:         name,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Status:       model.DocumentStatusProcessing,
+	}
+	if err := s.repo.CreateDocument(uid, doc); err != nil {
+		return nil, nil, err
+	}
+	return doc, chunks, nil
+}
+
+func (s *DefaultKnowledgeService) IndexDocument(ctx context.Context, doc *model.Document, chunks []string, embed EmbedFunc) error {
+	err := s.indexChunks(ctx, doc, chunks, embed)
+	if err != nil {
+		doc.Status = model.DocumentStatusFailed
+		doc.Error = err.Error()
+		// 清理已写入的部分向量
+		if err := s.index.DeleteByRef(collectionNamespace(doc.CollectionID), doc.ID); err != nil {
+			log.Warn("delete partial vectors failed", zap.String("document_id", doc.ID), zap.Error(err))
+		}
+	} else {
+		doc.Status = model.DocumentStatusReady
+		doc.Chunks = len(chunks)
+	}
+	if err := s.repo.UpdateDocument(doc); err != nil {
+		return err
+	}
+	return err
+}
+
+func (s *DefaultKnowledgeService) indexChunks(ctx context.Context, doc *model.Document, chunks []string, embed EmbedFunc) error {
+	namespace := collectionNamespace(doc.CollectionID)
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		batch := chunks[start:min(start+embedBatchSize, len(chunks))]
+		embeddings, err := embed(ctx, batch)
+		if err != nil {
+			return err
+		}
+		if len(embeddings) != len(batch) {
+			return fmt.Errorf("embedding model returned %d vectors for %d chunks", len(embeddings), len(batch))
+		}
+		records := make([]model.Chunk, len(batch))
+		vectors := make([]model.Vector, len(batch))
+		for i, content := range batch {
+			records[i] = model.Chunk{
+				ID:           uuid.NewString(),
+				CollectionID: doc.CollectionID,
+				DocumentID:   doc.ID,
+				Index:        start + i,
+				Content:      content,
+			}
+			vectors[i] = model.Vector{
+				ID:     records[i].ID,
+				Ref:    doc.ID,
+				Values: embeddings[i],
+			}
+		}
+		if err := s.repo.CreateChunks(records); err != nil {
+			return err
+		}
+		if err := s.index.Upsert(namespace, vectors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DefaultKnowledgeService) DeleteDocument(uid, id string) error {
+	doc, err := s.repo.GetDocument(uid, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrDocumentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteDocument(uid, id); err != nil {
+		return err
+	}
+	return s.index.DeleteByRef(collectionNamespace(doc.CollectionID), id)
+}
+
+func (s *DefaultKnowledgeService) Retrieve(uid, collectionID string, query []float32) ([]model.RetrievedChunk, error) {
+	if _, err := s.GetCollection(uid, collectionID); err != nil {
+		return nil, err
+	}
+	matches, err := s.index.Search(collectionNamespace(collectionID), query, s.topK)
+	if err != nil {
+		return nil, err
+	}
+	scores := map[string]float64{}
+	var ids []string
+	for _, match := range matches {
+		if match.Score >= s.minScore {
+			scores[match.ID] = match.Score
+			ids = append(ids, match.ID)
+		}
+	}
+	chunks, err := s.repo.GetChunks(collectionID, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]model.RetrievedChunk{}
+	for _, chunk := range chunks {
+		chunk.Score = scores[chunk.ID]
+		byID[chunk.ID] = chunk
+	}
+	// 按相似度排列，已删除文档残留的向量被忽略
+	result := make([]model.RetrievedChunk, 0, len(ids))
+	for _, id := range ids {
+		if chunk, ok := byID[id]; ok {
+			result = append(result, chunk)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/pkg/log"
+	"github.com/coxlong/eureka/internal/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRequestsExceeded = errors.New("requests per minute exceeded")
+	ErrTokensExceeded   = errors.New("tokens per day exceeded")
+)
+
+// counterCleanupInterval 清理过期计数的最小间隔
+const counterCleanupInterval = time.Minute
+
+// QuotaExceededError 超出限额时返回，Err为ErrRequestsExceeded或ErrTokensExceeded
+type QuotaExceededError struct {
+	Err   error
+	Limit model.QuotaLimit
+}
+
+func (e *QuotaExceededError) Error() string {
+	scope := "all models"
+	if e.Limit.Model != "" {
+		scope = "model " + e.Limit.Model
+	}
+	if errors.Is(e.Err, ErrTokensExceeded) {
+		return fmt.Sprintf("Rate limit reached for %s: limit %d tokens per day", scope, e.Limit.TokensPerDay)
+	}
+	return fmt.Sprintf("Rate limit reached for %s: limit %d requests per minute", scope, e.Limit.RequestsPerMinute)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return e.Err
+}
+
+type QuotaService interface {
+	// Check 计入本次请求并检查defaults中每个范围的限额，用户在数据库中的覆盖配置优先。
+	// 超出时返回*QuotaExceededError且不计入本次请求，同时返回的状态仍可用于响应头
+	Check(uid string, defaults []model.QuotaLimit, promptTokens int) (*model.QuotaStatus, error)
+	// Peek 与Check一样检查限额但不计入本次请求，用于在耗时的准备工作之前提前拒绝
+	Peek(uid string, defaults []model.QuotaLimit, promptTokens int) (*model.QuotaStatus, error)
+	GetOverrides(uid string) ([]model.QuotaLimit, error)
+	SaveOverride(uid string, limit *model.QuotaLimit) error
+	DeleteOverride(uid, model string) error
+}
+
+func NewQuotaService(r repository.QuotaRepo, usageRepo repository.UsageRepo) QuotaService {
+	return &DefaultQuotaService{repo: r, usageRepo: usageRepo}
+}
+
+type DefaultQuotaService struct {
+	repo      repository.QuotaRepo
+	usageRepo repository.UsageRepo
+	// lastCleanup 上次清理过期计数的时间(UnixNano)
+	lastCleanup atomic.Int64
+}
+
+func (s *DefaultQuotaService) Check(uid string, defaults []model.QuotaLimit, promptTokens int) (*model.QuotaStatus, error) {
+	return s.check(uid, defaults, promptTokens, true)
+}
+
+func (s *DefaultQuotaService) Peek(uid string, defaults []model.QuotaLimit, promptTokens int) (*model.QuotaStatus, error) {
+	return s.check(uid, defaults, promptTokens, false)
+}
+
+// check count为false时只读取计数，按计入本次请求后的值比较
+func (s *DefaultQuotaService) check(uid string, defaults []model.QuotaLimit, promptTokens int, count bool) (*model.QuotaStatus, error) {
+	now := time.Now()
+	s.cleanup(now)
+	overrides, err := s.repo.GetOverrides(uid)
+	if err != nil {
+		return nil, err
+	}
+	overrideMap := map[string]model.QuotaLimit{}
+	for _, item := range overrides {
+		overrideMap[item.Model] = item
+	}
+
+	status := &model.QuotaStatus{}
+	window := now.Truncate(time.Minute)
+	day := now.UTC().Truncate(24 * time.Hour)
+	var exceeded error
+	// 先计入再比较，并发的请求不会同时通过；请求被拒绝时撤销已经计入的次数
+	limits := make([]model.QuotaLimit, len(defaults))
+	hits := make([]int64, len(defaults))
+	var counted []string
+	for i, limit := range defaults {
+		if override, ok := overrideMap[limit.Model]; ok {
+			limit = override
+		}
+		limits[i] = limit
+		if limit.RequestsPerMinute > 0 {
+			key := fmt.Sprintf("rpm:%s:%s:%d", uid, limit.Model, window.Unix())
+			if count {
+				hits[i], err = s.repo.IncrCounter(key, window.Add(time.Minute))
+				if err != nil {
+					s.uncount(counted)
+					return nil, err
+				}
+				counted = append(counted, key)
+			} else {
+				hits[i], err = s.repo.GetCounter(key)
+				if err != nil {
+					return nil, err
+				}
+				hits[i]++
+			}
+			if int(hits[i]) > limit.RequestsPerMinute && exceeded == nil {
+				exceeded = &QuotaExceededError{Err: ErrRequestsExceeded, Limit: limit}
+			}
+		}
+		if limit.TokensPerDay > 0 {
+			used, err := s.usageRepo.SumTokens(uid, limit.Model, day)
+			if err != nil {
+				s.uncount(counted)
+				return nil, err
+			}
+			remaining := max(limit.TokensPerDay-int(used), 0)
+			if status.TokensLimit == 0 || remaining < status.TokensRemaining {
+				status.TokensLimit = limit.TokensPerDay
+				status.TokensRemaining = remaining
+				status.TokensReset = day.AddDate(0, 0, 1).Sub(now)
+			}
+			// prompt的token数在发出请求前已知，回答的token数只能事后计入
+			if int(used)+promptTokens > limit.TokensPerDay && exceeded == nil {
+				exceeded = &QuotaExceededError{Err: ErrTokensExceeded, Limit: limit}
+			}
+		}
+	}
+	if exceeded != nil {
+		s.uncount(counted)
+	}
+	for i, limit := range limits {
+		if limit.RequestsPerMinute == 0 {
+			continue
+		}
+		used := hits[i]
+		if exceeded != nil || !count {
+			used--
+		}
+		remaining := max(limit.RequestsPerMinute-int(used), 0)
+		if status.RequestsLimit == 0 || remaining < status.RequestsRemaining {
+			status.RequestsLimit = limit.RequestsPerMinute
+			status.RequestsRemaining = remaining
+			status.RequestsReset = window.Add(time.Minute).Sub(now)
+		}
+	}
+	return status, exceeded
+}
+
+// uncount 撤销本次请求已经计入的次数
+func (s *DefaultQuotaService) uncount(keys []string) {
+	for _, key := range keys {
+		if err := s.repo.DecrCounter(key); err != nil {
+			log.Warn("undo rate counter failed", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// cleanup 定期在后台删除过期的计数
+func (s *DefaultQuotaService) cleanup(now time.Time) {
+	last := s.lastCleanup.Load()
+	if now.UnixNano()-last < int64(counterCleanupInterval) || !s.lastCleanup.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	go s.repo.DeleteExpiredCounters(now)
+}
+
+func (s *DefaultQuotaService) GetOverrides(uid string) ([]model.QuotaLimit, error) {
+	return s.repo.GetOverrides(uid)
+}
+
+func (s *DefaultQuotaService) SaveOverride(uid string, limit *model.QuotaLimit) error {
+	return s.repo.SaveOverride(uid, limit)
+}
+
+func (s *DefaultQuotaService) DeleteOverride(uid, model string) error {
+	return s.repo.DeleteOverride(uid, model)
+}
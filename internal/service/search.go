@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/repository"
+)
+
+const (
+	// indexMaxRunes 计算向量时每条消息保留的字符数，超出部分对语义的影响不大
+	indexMaxRunes = 4000
+	// snippetLength 搜索结果中的摘录字符数
+	snippetLength = 200
+	// snippetContext 关键词搜索的摘录中第一个命中之前保留的字符数
+	snippetContext = 60
+	// searchMaxTerms 一次搜索最多使用的关键词数
+	searchMaxTerms = 8
+)
+
+type SearchService interface {
+	// IndexMessages 计算消息的向量并写入用户的索引，只索引有文本的用户和助手消息
+	IndexMessages(ctx context.Context, uid, cid string, messages []model.Message, embed EmbedFunc) error
+	// SemanticSearch 返回用户会话中与query最相似的消息，按相似度降序排列
+	SemanticSearch(uid string, query []float32, limit int) ([]model.SearchResult, error)
+	// Search 按关键词搜索用户的会话标题和消息内容，标题命中的会话排在前面，
+	// 多个关键词之间为AND
+	Search(uid, query string, limit int) ([]model.SearchResult, error)
+}
+
+func NewSearchService(r repository.ConversationsRepo, index repository.VectorIndex) SearchService {
+	return &DefaultSearchService{r, index}
+}
+
+type DefaultSearchService struct {
+	repo  repository.ConversationsRepo
+	index repository.VectorIndex
+}
+
+// messagesNamespace 用户消息在向量索引中的namespace，向量的Ref为会话ID
+func messagesNamespace(uid string) string {
+	return "messages:" + uid
+}
+
+func (s *DefaultSearchService) IndexMessages(ctx context.Context, uid, cid string, messages []model.Message, embed EmbedFunc) error {
+	var ids, inputs []string
+	for _, item := range messages {
+		if item.Role != model.RoleUser && item.Role != model.RoleAssistant {
+			continue
+		}
+		text := []rune(strings.TrimSpace(item.Text()))
+		if len(text) == 0 {
+			continue
+		}
+		ids = append(ids, item.ID)
+		inputs = append(inputs, string(text[:min(len(text), indexMaxRunes)]))
+	}
+	for start := 0; start < len(inputs); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(inputs))
+		embeddings, err := embed(ctx, inputs[start:end])
+		if err != nil {
+			return err
+		}
+		if len(embeddings) != end-start {
+			return fmt.Errorf("embedding model returned %d vectors for %d messages", len(embeddings), end-start)
+		}
+		vectors := make([]model.Vector, len(embeddings))
+		for i, values := range embeddings {
+			vectors[i] = model.Vector{ID: ids[start+i], Ref: cid, Values: values}
+		}
+		if err := s.index.Upsert(messagesNamespace(uid), vectors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DefaultSearchService) SemanticSearch(uid string, query []float32, limit int) ([]model.SearchResult, error) {
+	matches, err := s.index.Search(messagesNamespace(uid), query, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	messages, err := s.repo.GetMessagesByIDs(uid, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]model.ConversationMessage{}
+	for _, item := range messages {
+		byID[item.ID] = item
+	}
+	// 已删除的会话和消息残留的向量被忽略
+	result := []model.SearchResult{}
+	for _, match := range matches {
+		item, ok := byID[match.ID]
+		if !ok {
+			continue
+		}
+		text := []rune(strings.TrimSpace(item.Text()))
+		result = append(result, model.SearchResult{
+			ConversationID: item.ConversationID,
+			Title:          item.Title,
+			MessageID:      item.ID,
+			Role:           item.Role,
+			Snippet:        string(text[:min(len(text), snippetLength)]),
+			Score:          match.Score,
+			CreatedAt:      item.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+func (s *DefaultSearchService) Search(uid, query string, limit int) ([]model.SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []model.SearchResult{}, nil
+	}
+	conversations, err := s.repo.SearchTitles(uid, terms, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := []model.SearchResult{}
+	for _, item := range conversations {
+		snippet, highlight := highlightTerms(item.Title, terms)
+		result = append(result, model.SearchResult{
+			ConversationID: item.ID,
+			Title:          item.Title,
+			Snippet:        snippet,
+			Highlight:      highlight,
+			CreatedAt:      item.CreatedAt,
+		})
+	}
+	if len(result) >= limit {
+		return result, nil
+	}
+	messages, err := s.repo.SearchMessages(uid, terms, limit-len(result))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range messages {
+		snippet, highlight := highlightTerms(item.Text(), terms)
+		result = append(result, model.SearchResult{
+			ConversationID: item.ConversationID,
+			Title:          item.Title,
+			MessageID:      item.ID,
+			Role:           item.Role,
+			Snippet:        snippet,
+			Highlight:      highlight,
+			CreatedAt:      item.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// searchTerms 把搜索字符串按空白切分为关键词，忽略大小写去重
+func searchTerms(query string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, term := range strings.Fields(query) {
+		key := strings.ToLower(term)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		terms = append(terms, term)
+		if len(terms) == searchMaxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// highlightTerms 截取第一个命中附近的摘录，返回摘录以及HTML转义后用<mark>标记关键词的摘录。
+// 按字符忽略大小写匹配
+func highlightTerms(text string, terms []string) (string, string) {
+	runes := []rune(strings.TrimSpace(text))
+	lower := lowerRunes(string(runes))
+	// ranges 命中的字符区间[start, end)
+	var ranges [][2]int
+	for _, term := range terms {
+		pattern := lowerRunes(term)
+		for i := 0; i+len(pattern) <= len(lower); i++ {
+			if string(lower[i:i+len(pattern)]) == string(pattern) {
+				ranges = append(ranges, [2]int{i, i + len(pattern)})
+			}
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	start := 0
+	if len(ranges) > 0 {
+		start = max(0, ranges[0][0]-snippetContext)
+	}
+	end := min(len(runes), start+snippetLength)
+
+	var highlight strings.Builder
+	pos := start
+	for _, r := range ranges {
+		// 跳过摘录之外和与前一个重叠的命中
+		if r[0] < pos || r[0] >= end {
+			continue
+		}
+		stop := min(r[1], end)
+		highlight.WriteString(html.EscapeString(string(runes[pos:r[0]])))
+		highlight.WriteString("<mark>")
+		highlight.WriteString(html.EscapeString(string(runes[r[0]:stop])))
+		highlight.WriteString("</mark>")
+		pos = stop
+	}
+	highlight.WriteString(html.EscapeString(string(runes[pos:end])))
+	return string(runes[start:end]), highlight.String()
+}
+
+// lowerRunes 逐个字符转为小写，字符数与原文一致
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, c := range runes {
+		runes[i] = unicode.ToLower(c)
+	}
+	return runes
+}
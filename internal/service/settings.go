@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/repository"
+	"gorm.io/gorm"
+)
+
+type SettingsService interface {
+	SaveSettings(uid string, settings *model.Settings) error
+	// GetSettings 返回用户的设置，未保存过时返回默认设置
+	GetSettings(uid string) (*model.Settings, error)
+}
+
+func NewSettingsService(r repository.SettingsRepo) SettingsService {
+	return &DefaultSettingsService{r}
+}
+
+type DefaultSettingsService struct {
+	repo repository.SettingsRepo
+}
+
+func (s *DefaultSettingsService) SaveSettings(uid string, settings *model.Settings) error {
+	return s.repo.SaveSettings(uid, settings)
+}
+
+func (s *DefaultSettingsService) GetSettings(uid string) (*model.Settings, error) {
+	settings, err := s.repo.GetSettings(uid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		defaults := model.DefaultSettings()
+		return &defaults, nil
+	}
+	return settings, err
+}
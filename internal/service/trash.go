@@ -0,0 +1,73 @@
+package service
+
+import (
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+)
+
+const (
+	defaultTrashRetentionDays = 30
+	// purgeBatchSize 自动清理时每批删除的会话数
+	purgeBatchSize = 100
+)
+
+func (s *DefaultConversationService) DeleteConversations(uid string, ids []string) ([]string, error) {
+	return s.repo.DeleteConversations(uid, ids)
+}
+
+func (s *DefaultConversationService) GetTrash(uid string, after *model.TrashCursor, limit int) ([]model.ConversationMeta, string, error) {
+	fetch := 0
+	if limit > 0 {
+		fetch = limit + 1
+	}
+	conversations, err := s.repo.GetDeletedConversations(uid, after, fetch)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 || len(conversations) <= limit {
+		return conversations, "", nil
+	}
+	conversations = conversations[:limit]
+	last := conversations[limit-1]
+	return conversations, model.TrashCursor{DeletedAt: last.DeletedAt, ID: last.ID}.String(), nil
+}
+
+func (s *DefaultConversationService) RestoreConversations(uid string, ids []string) ([]string, error) {
+	return s.repo.RestoreConversations(uid, ids)
+}
+
+func (s *DefaultConversationService) PurgeConversations(uid string, ids []string) ([]string, error) {
+	purged, err := s.repo.PurgeConversations(uid, ids)
+	if err != nil {
+		return nil, err
+	}
+	// 向量删除失败只会留下搜索时被忽略的残留向量
+	for _, cid := range purged {
+		if err := s.index.DeleteByRef(messagesNamespace(uid), cid); err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+func (s *DefaultConversationService) PurgeExpired(now time.Time) (int, error) {
+	if s.retentionDays < 0 {
+		return 0, nil
+	}
+	before := now.AddDate(0, 0, -s.retentionDays)
+	total := 0
+	for {
+		expired, err := s.repo.GetDeletedBefore(before, purgeBatchSize)
+		if err != nil || len(expired) == 0 {
+			return total, err
+		}
+		for uid, ids := range expired {
+			purged, err := s.PurgeConversations(uid, ids)
+			total += len(purged)
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/coxlong/eureka/internal/model"
+	"github.com/coxlong/eureka/internal/repository"
+)
+
+var ErrInvalidUsagePeriod = errors.New("period must be daily or monthly")
+
+type UsageService interface {
+	RecordUsage(uid string, record *model.UsageRecord) error
+	// GetUsage 按天或按月汇总[from, to)之间每个模型的用量
+	GetUsage(uid, period string, from, to time.Time) ([]model.UsageStat, error)
+}
+
+func NewUsageService(r repository.UsageRepo) UsageService {
+	return &DefaultUsageService{r}
+}
+
+type DefaultUsageService struct {
+	repo repository.UsageRepo
+}
+
+func (s *DefaultUsageService) RecordUsage(uid string, record *model.UsageRecord) error {
+	return s.repo.CreateUsage(uid, record)
+}
+
+func (s *DefaultUsageService) GetUsage(uid, period string, from, to time.Time) ([]model.UsageStat, error) {
+	if period != model.UsagePeriodDaily && period != model.UsagePeriodMonthly {
+		return nil, ErrInvalidUsagePeriod
+	}
+	return s.repo.GetUsageStats(uid, period, from, to)
+}
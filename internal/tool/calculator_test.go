@@ -0,0 +1,92 @@
+package tool
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		expression string
+		want       float64
+	}{
+		{"1 + 2", 3},
+		{"2 + 3 * 4", 14},
+		{"2 * 3 + 4", 10},
+		{"(2 + 3) * 4", 20},
+		{"10 - 2 - 3", 5},
+		{"8 / 2 / 2", 2},
+		{"10 % 3", 1},
+		{"2 + 10 % 4 * 2", 6},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"(-2) ^ 2", 4},
+		{"2 ^ -1", 0.5},
+		{"2 * -3", -6},
+		{"--3", 3},
+		{"+3", 3},
+		{"1.5e3 + 1E-3", 1500.001},
+		{".5 * 4", 2},
+		{"sqrt(16) + abs(-2)", 6},
+		{"max(1, 2 * 3, 4) - min(5, 2)", 4},
+		{"pow(2, 10)", 1024},
+		{"round(2.5) + floor(1.9) + ceil(1.1)", 6},
+		{"2 * pi", 2 * math.Pi},
+		{"ln(e)", 1},
+		{"SQRT(4)", 2},
+	}
+	for _, c := range cases {
+		got, err := Evaluate(c.expression)
+		if err != nil {
+			t.Errorf("Evaluate(%q): %v", c.expression, err)
+			continue
+		}
+		if math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("Evaluate(%q) = %v, want %v", c.expression, got, c.want)
+		}
+	}
+}
+
+func TestEvaluateError(t *testing.T) {
+	cases := []struct {
+		expression string
+		want       string
+	}{
+		{"1 / 0", "division by zero"},
+		{"1 / (2 - 2)", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"", "unexpected end of expression"},
+		{"1 +", "unexpected end of expression"},
+		{"(1 + 2", "missing )"},
+		{"2 * )", "unexpected ')'"},
+		{"1 2", "unexpected '2'"},
+		{"1..2", "invalid syntax"},
+		{"2 $ 3", "unexpected '$'"},
+		{"foo", "unknown constant foo"},
+		{"bogus(1)", "unknown function bogus"},
+		{"sqrt(1, 2)", "sqrt: expects 1 argument"},
+		{"pow(2)", "pow: pow expects 2 arguments"},
+		{"max()", "max: max expects at least 1 argument"},
+		{"sqrt(4", "missing )"},
+		{"sqrt(-1)", "not a finite number"},
+		{"10 ^ 400", "not a finite number"},
+	}
+	for _, c := range cases {
+		_, err := Evaluate(c.expression)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Evaluate(%q) error = %v, want %q", c.expression, err, c.want)
+		}
+	}
+}
+
+func TestCalculatorCall(t *testing.T) {
+	result, err := Calculator{}.Call(context.Background(), `{"expression":"(1 + 2) * 4 / 8"}`)
+	if err != nil || result != "1.5" {
+		t.Fatalf("got %q %v, want 1.5", result, err)
+	}
+	if _, err := (Calculator{}).Call(context.Background(), `{"expression":`); err == nil {
+		t.Fatal("malformed arguments should fail")
+	}
+}
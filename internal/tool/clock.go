@@ -0,0 +1,40 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Clock 返回当前时间
+type Clock struct{}
+
+func (Clock) Definition() openai.FunctionDefinition {
+	return openai.FunctionDefinition{
+		Name:        "current_time",
+		Description: "Get the current date and time.",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"timezone":{"type":"string","description":"IANA time zone such as Asia/Shanghai, defaults to UTC"}}}`),
+	}
+}
+
+func (Clock) Call(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", err
+		}
+	}
+	loc := time.UTC
+	if args.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(args.Timezone); err != nil {
+			return "", err
+		}
+	}
+	now := time.Now().In(loc)
+	return now.Format(time.RFC3339) + " (" + now.Weekday().String() + ")", nil
+}
@@ -0,0 +1,115 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultFetchMaxBytes = 64 << 10
+	defaultFetchTimeout  = 10 * time.Second
+	maxFetchRedirects    = 5
+)
+
+// Fetch 通过HTTP GET读取允许的主机上的内容
+type Fetch struct {
+	allowedHosts []string
+	maxBytes     int64
+	client       *http.Client
+}
+
+func NewFetch(cfg *config.Fetch) *Fetch {
+	f := &Fetch{
+		allowedHosts: cfg.AllowedHosts,
+		maxBytes:     int64(cfg.MaxBytes),
+	}
+	if f.maxBytes <= 0 {
+		f.maxBytes = defaultFetchMaxBytes
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultFetchTimeout
+	}
+	f.client = &http.Client{
+		Timeout: timeout,
+		// 重定向的目标同样需要在允许的主机内
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return errors.New("too many redirects")
+			}
+			return f.check(req.URL)
+		},
+	}
+	return f
+}
+
+func (f *Fetch) Definition() openai.FunctionDefinition {
+	return openai.FunctionDefinition{
+		Name:        "http_fetch",
+		Description: "Fetch a web page or API response with HTTP GET. Only hosts allowed by the server can be fetched: " + strings.Join(f.allowedHosts, ", "),
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"url":{"type":"string","description":"absolute http or https URL"}},"required":["url"]}`),
+	}
+}
+
+func (f *Fetch) Call(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(args.URL)
+	if err != nil {
+		return "", err
+	}
+	if err := f.check(u); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	truncated := ""
+	if int64(len(body)) > f.maxBytes {
+		body = body[:f.maxBytes]
+		truncated = "\n[truncated]"
+	}
+	return fmt.Sprintf("HTTP %d %s\n\n%s%s", resp.StatusCode, resp.Header.Get("Content-Type"), body, truncated), nil
+}
+
+// check 只允许http(s)访问配置的主机，"*.example.com"匹配所有子域名
+func (f *Fetch) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range f.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed {
+			return nil
+		}
+		if domain, ok := strings.CutPrefix(allowed, "*."); ok && strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s is not allowed", host)
+}
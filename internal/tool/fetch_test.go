@@ -0,0 +1,110 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/coxlong/eureka/internal/pkg/config"
+)
+
+func TestFetchCheck(t *testing.T) {
+	f := NewFetch(&config.Fetch{AllowedHosts: []string{"api.example.org", "*.example.com"}})
+	cases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://api.example.org/v1", true},
+		{"http://API.Example.org:8080/", true},
+		{"https://www.example.com/", true},
+		{"https://a.b.example.com/", true},
+		// 通配符只匹配子域名
+		{"https://example.com/", false},
+		{"https://evil.com/", false},
+		{"https://evilexample.com/", false},
+		{"https://example.com.evil.com/", false},
+		{"https://www.example.com.evil.com/", false},
+		{"https://api.example.org.evil.com/", false},
+		{"https://evil.com/?next=https://www.example.com", false},
+		{"https://www.example.com@evil.com/", false},
+		{"ftp://www.example.com/", false},
+		{"file:///etc/passwd", false},
+	}
+	for _, c := range cases {
+		u, err := url.Parse(c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.check(u); (err == nil) != c.allowed {
+			t.Errorf("check(%s) = %v, want allowed %v", c.url, err, c.allowed)
+		}
+	}
+}
+
+func TestFetchCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, strings.Repeat("a", 20))
+	}))
+	defer server.Close()
+	f := NewFetch(&config.Fetch{AllowedHosts: []string{"127.0.0.1"}, MaxBytes: 10})
+
+	result, err := f.Call(context.Background(), arguments(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "HTTP 200 text/plain\n\naaaaaaaaaa\n[truncated]"; result != want {
+		t.Fatalf("got %q, want %q", result, want)
+	}
+
+	// localhost与127.0.0.1是不同的主机
+	_, err = f.Call(context.Background(), arguments(strings.Replace(server.URL, "127.0.0.1", "localhost", 1)))
+	if err == nil || !strings.Contains(err.Error(), "host localhost is not allowed") {
+		t.Fatalf("got %v, want host not allowed", err)
+	}
+}
+
+func TestFetchRedirect(t *testing.T) {
+	var hits int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		fmt.Fprint(w, "secret")
+	}))
+	defer target.Close()
+	// 允许的主机重定向到不允许的主机
+	disallowed := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/allowed":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/final":
+			fmt.Fprint(w, "ok")
+		default:
+			http.Redirect(w, r, disallowed, http.StatusFound)
+		}
+	}))
+	defer server.Close()
+	f := NewFetch(&config.Fetch{AllowedHosts: []string{"127.0.0.1"}})
+
+	result, err := f.Call(context.Background(), arguments(server.URL+"/allowed"))
+	if err != nil || !strings.HasSuffix(result, "ok") {
+		t.Fatalf("got %q %v, want redirect within allowed host to succeed", result, err)
+	}
+	_, err = f.Call(context.Background(), arguments(server.URL+"/evil"))
+	if err == nil || !strings.Contains(err.Error(), "host localhost is not allowed") {
+		t.Fatalf("got %v, want host not allowed", err)
+	}
+	if hits != 0 {
+		t.Fatal("disallowed host was requested")
+	}
+}
+
+func arguments(u string) string {
+	data, _ := json.Marshal(map[string]string{"url": u})
+	return string(data)
+}
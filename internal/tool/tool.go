@@ -0,0 +1,74 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/coxlong/eureka/internal/pkg/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// callTimeout 单次工具调用的最长时间
+const callTimeout = 30 * time.Second
+
+// Tool 服务端执行的工具，参数和返回值与OpenAI的function calling一致
+type Tool interface {
+	Definition() openai.FunctionDefinition
+	// Call 执行工具，arguments为模型生成的JSON参数
+	Call(ctx context.Context, arguments string) (string, error)
+}
+
+// Registry 服务端可用的工具
+type Registry struct {
+	tools map[string]Tool
+	names []string
+}
+
+// NewRegistry 创建包含内置工具的注册表，http_fetch只在配置了允许的主机时注册
+func NewRegistry(cfg *config.Tools) *Registry {
+	r := &Registry{tools: map[string]Tool{}}
+	r.Register(Calculator{})
+	r.Register(Clock{})
+	if len(cfg.Fetch.AllowedHosts) > 0 {
+		r.Register(NewFetch(&cfg.Fetch))
+	}
+	return r
+}
+
+func (r *Registry) Register(t Tool) {
+	name := t.Definition().Name
+	if _, ok := r.tools[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.tools[name] = t
+}
+
+func (r *Registry) Get(name string) (Tool, bool) {
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Definitions 返回所有工具的定义，按注册顺序排列
+func (r *Registry) Definitions() []openai.FunctionDefinition {
+	result := make([]openai.FunctionDefinition, 0, len(r.names))
+	for _, name := range r.names {
+		result = append(result, r.tools[name].Definition())
+	}
+	return result
+}
+
+// Call 执行一次工具调用，出错时把错误作为结果返回给模型
+func (r *Registry) Call(ctx context.Context, call openai.ToolCall) string {
+	t, ok := r.tools[call.Function.Name]
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %s", call.Function.Name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	result, err := t.Call(ctx, call.Function.Arguments)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return result
+}